@@ -4,6 +4,7 @@
 package main
 
 import (
+	"encoding/csv"
 	"fmt"
 	"image"
 	"image/color"
@@ -11,6 +12,9 @@ import (
 	"os"
 	"regexp"
 	"strconv"
+	"strings"
+
+	"github.com/gbin/goncurses"
 )
 
 type xy struct {
@@ -32,33 +36,152 @@ type robot struct {
 type area struct {
 	width, height int
 	robots        []robot
+
+	// Number of robots on each plot, built when first needed and cleared whenever robots move
+	grid []int
 }
 
 // Move all the robots in the area one time
 // Robots move according to their velocity, and can teleport to the other side of the area if they go out of bounds
 func (a *area) moveRobots() {
-	for i, robot := range a.robots {
-		newPos := robot.pos.Add(robot.vel)
+	for i, pos := range a.positionsAt(1) {
+		a.robots[i].pos = pos
+	}
+
+	a.grid = nil
+}
+
+// Find where every robot will be after `t` ticks without moving them
+// Robots wrap around the area, so only the remainder of the ticks over the width and height matter
+func (a *area) positionsAt(t int) []point {
+	positions := make([]point, len(a.robots))
+	tx, ty := t%a.width, t%a.height
 
-		// If position goes outside area, wrap it around
-		if newPos.y < 0 {
-			newPos.y += a.height
+	for i, robot := range a.robots {
+		positions[i] = point{
+			mod(robot.pos.x+robot.vel.x*tx, a.width),
+			mod(robot.pos.y+robot.vel.y*ty, a.height),
 		}
+	}
+
+	return positions
+}
+
+// Create a copy of the area with every robot where it will be after `t` ticks
+func (a *area) at(t int) *area {
+	moved := newArea(a.width, a.height)
+
+	for i, pos := range a.positionsAt(t) {
+		moved.robots = append(moved.robots, robot{pos, a.robots[i].vel})
+	}
+
+	return moved
+}
+
+// Number of ticks before the robots' x and y positions repeat
+// Each robot's position along an axis repeats once its velocity has carried it a whole number of times across the area
+func (a *area) periods() (int, int) {
+	px, py := 1, 1
+
+	for _, robot := range a.robots {
+		px = lcm(px, a.width/gcd(mod(robot.vel.x, a.width), a.width))
+		py = lcm(py, a.height/gcd(mod(robot.vel.y, a.height), a.height))
+	}
+
+	return px, py
+}
+
+// Number of ticks before every robot is back where it started
+func (a *area) period() int {
+	px, py := a.periods()
+
+	return lcm(px, py)
+}
+
+// Find the tick where the robots are clustered closest together
+// The x and y positions repeat separately, so the tick where each is least spread out is found within its own period
+// Those two ticks are then combined with the chinese remainder theorem
+// Returns false if no tick lines up with both
+func (a *area) clusterTick() (int, bool) {
+	px, py := a.periods()
+
+	spread := func(t int, axis func(p point) int) int {
+		sum, sumSq := 0, 0
 
-		if newPos.y >= a.height {
-			newPos.y = newPos.y % a.height
+		for _, pos := range a.positionsAt(t) {
+			sum += axis(pos)
+			sumSq += axis(pos) * axis(pos)
 		}
 
-		if newPos.x < 0 {
-			newPos.x += a.width
+		// Variance multiplied by the robot count squared to stay in whole numbers
+		return len(a.robots)*sumSq - sum*sum
+	}
+
+	bestX, bestY := 0, 0
+
+	for t := 1; t < px; t++ {
+		if spread(t, func(p point) int { return p.x }) < spread(bestX, func(p point) int { return p.x }) {
+			bestX = t
 		}
+	}
 
-		if newPos.x >= a.width {
-			newPos.x = newPos.x % a.width
+	for t := 1; t < py; t++ {
+		if spread(t, func(p point) int { return p.y }) < spread(bestY, func(p point) int { return p.y }) {
+			bestY = t
 		}
+	}
+
+	t, ok := crt(bestX, px, bestY, py)
+
+	// A cluster at the start is seen again once the period is over
+	if ok && t == 0 {
+		t = lcm(px, py)
+	}
+
+	return t, ok
+}
+
+// Solve t = a (mod m) and t = b (mod n) for the smallest non-negative t
+// The moduli don't need to be coprime, but when they aren't there might not be a solution
+func crt(a, m, b, n int) (int, bool) {
+	g, inv, _ := extendedGcd(m, n)
 
-		a.robots[i].pos = newPos
+	if (b-a)%g != 0 {
+		return 0, false
 	}
+
+	l := m / g * n
+	k := mod((b-a)/g*inv, n/g)
+
+	return mod(a+m*k, l), true
+}
+
+// Returns gcd(a, b) along with x and y where a*x + b*y = gcd(a, b)
+func extendedGcd(a, b int) (int, int, int) {
+	if b == 0 {
+		return a, 1, 0
+	}
+
+	g, x, y := extendedGcd(b, a%b)
+
+	return g, y, x - (a/b)*y
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
+
+func lcm(a, b int) int {
+	return a / gcd(a, b) * b
+}
+
+// Remainder that's always positive, unlike %
+func mod(a, m int) int {
+	return ((a % m) + m) % m
 }
 
 // Groups robots by the quardrant they're in
@@ -98,58 +221,53 @@ func (a *area) robotsByQuadrant() [4][]robot {
 	return quads
 }
 
+// Multiply the number of robots in each quadrant together
+// A quadrant without any robots makes the safety factor 0
+func (a *area) safetyFactor() int {
+	safetyFactor := 1
+
+	for _, robots := range a.robotsByQuadrant() {
+		safetyFactor *= len(robots)
+	}
+
+	return safetyFactor
+}
+
 // Return a map of the area, showing the number of robots in each position
 func (a *area) String() string {
-	var str string
+	var str strings.Builder
+	grid := a.occupancy()
 
 	for y := 0; y < a.height; y++ {
 		for x := 0; x < a.width; x++ {
-			// Find number of robots at this point
-			pos := point{x, y}
-			count := 0
-
-			for _, robot := range a.robots {
-				if robot.pos == pos {
-					count++
-				}
-			}
-
-			if count > 0 {
-				str += strconv.Itoa(count)
+			if count := grid[y*a.width+x]; count > 0 {
+				str.WriteString(strconv.Itoa(count))
 			} else {
-				str += "."
+				str.WriteByte('.')
 			}
 		}
 
-		str += "\n"
+		str.WriteByte('\n')
 	}
 
-	return str
+	return str.String()
 }
 
+// Palette for area images, where the index is 1 for plots with at least one robot
+var areaPalette = color.Palette{color.Black, color.White}
+
 // Create an image of the area
 // Unoccupied plots will be a black pixel, while plots with at least one robot will be a white pixel
-func (a *area) ToImage() *image.RGBA {
-	img := image.NewRGBA(image.Rectangle{image.Point{0, 0}, image.Point{a.width, a.height}})
+func (a *area) ToImage() *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, a.width, a.height), areaPalette)
+	grid := a.occupancy()
 
 	for y := 0; y < a.height; y++ {
-		for x := 0; x < a.width; x++ {
-			// Find number of robots at this point
-			pos := point{x, y}
-			count := 0
+		row := img.Pix[y*img.Stride : y*img.Stride+a.width]
 
-			for _, robot := range a.robots {
-				if robot.pos == pos {
-					count++
-					break
-				}
-			}
-
-			// If there was at least one robot, put a white pixel
-			if count > 0 {
-				img.Set(x, y, color.White)
-			} else {
-				img.Set(x, y, color.Black)
+		for x := range row {
+			if grid[y*a.width+x] > 0 {
+				row[x] = 1
 			}
 		}
 	}
@@ -163,12 +281,19 @@ func newArea(width, height int) *area {
 		width,
 		height,
 		make([]robot, 0),
+		nil,
 	}
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "animate" {
+		animate()
+		return
+	}
+
 	if cap(os.Args) < 6 {
-		fmt.Fprintf(os.Stderr, "Usage: %s width height max-seconds [input-file] [output-folder]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s width height max-seconds [input-file] [output-folder] [safety/variance/run/component/crt] [threshold]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s animate width height [input-file]\n", os.Args[0])
 		os.Exit(-1)
 	}
 
@@ -193,41 +318,410 @@ func main() {
 	area := newArea(width, height)
 	loadRobotsFromInput(area, os.Args[4])
 
-	generateFiles(area, maxSeconds, os.Args[5])
+	// Part 1 requires the safety factor after 100 ticks
+	fmt.Println("Part 1:", area.at(100).safetyFactor())
+	fmt.Println("Period:", area.period())
+
+	// Robots are back where they started after the period, so there's no need to look further
+	if maxSeconds <= 0 {
+		maxSeconds = area.period()
+	}
+
+	if len(os.Args) < 7 {
+		generateFiles(area, maxSeconds, os.Args[5])
+		return
+	}
+
+	if os.Args[6] == "crt" {
+		found, ok := area.clusterTick()
+
+		if !ok {
+			fmt.Println("No tree found")
+			return
+		}
+
+		saveTree(area, found, os.Args[5])
+		return
+	}
+
+	detector, ok := treeDetectors[os.Args[6]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Invalid detector\n")
+		os.Exit(-1)
+	}
+
+	if len(os.Args) > 7 {
+		if detector.threshold, err = strconv.Atoi(os.Args[7]); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid threshold\n")
+			os.Exit(-1)
+		}
+	}
+
+	detectTree(area, maxSeconds, detector, os.Args[5])
 }
 
 // Generate an image for every tick up to `ticks`
 // Each image will be saved to disk with the tick and the calculated safety factor
+// The safety factor of every tick is also saved to safety.csv
 // For Part 1, we need the safety factor of the 100th tick
 // For Part 2, we have to generate images of X ticks and look for one where a christmas tree appears
 func generateFiles(area *area, ticks int, outputFolder string) {
+	summary, err := os.Create(fmt.Sprintf("%s/safety.csv", outputFolder))
+	if err != nil {
+		panic(err)
+	}
+
+	defer summary.Close()
+
+	w := csv.NewWriter(summary)
+	w.Write([]string{"tick", "safety_factor"})
+
 	for i := 0; i < ticks; i++ {
-		area.moveRobots()
+		frame := area.at(i + 1)
+		safetyFactor := frame.safetyFactor()
+
+		w.Write([]string{strconv.Itoa(i + 1), strconv.Itoa(safetyFactor)})
+
+		// Output filename is the number of ticks elapsed and the safety factor
+		writeImage(fmt.Sprintf("%s/%d - %d.png", outputFolder, i+1, safetyFactor), frame.ToImage())
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		panic(err)
+	}
+}
+
+// Count the robots on each plot, indexed by y * width + x
+// The counts are kept until the robots move, so renderers and detectors can share them
+func (a *area) occupancy() []int {
+	if a.grid != nil {
+		return a.grid
+	}
+
+	a.grid = make([]int, a.width*a.height)
+
+	for _, robot := range a.robots {
+		a.grid[robot.pos.y*a.width+robot.pos.x]++
+	}
+
+	return a.grid
+}
+
+// Scores a tick by how likely it is to show the christmas tree, where higher scores are more likely
+type treeDetector struct {
+	score func(a *area, grid []int) int
+
+	// The first tick scoring at least the threshold is the tree
+	// Without a threshold, the highest scoring tick is the tree
+	threshold int
+}
+
+var treeDetectors = map[string]treeDetector{
+	// The tree gathers most robots into one quadrant, giving the lowest safety factor
+	"safety": {
+		score: func(a *area, grid []int) int {
+			return -a.safetyFactor()
+		},
+	},
+
+	// The tree gathers robots together, so their positions vary the least
+	"variance": {
+		score: func(a *area, grid []int) int {
+			var sumX, sumY, sumSqX, sumSqY int
+
+			for _, robot := range a.robots {
+				sumX += robot.pos.x
+				sumY += robot.pos.y
+				sumSqX += robot.pos.x * robot.pos.x
+				sumSqY += robot.pos.y * robot.pos.y
+			}
+
+			// Sum of the variances, multiplied by the robot count squared to stay in whole numbers
+			n := len(a.robots)
+
+			return -((n*sumSqX - sumX*sumX) + (n*sumSqY - sumY*sumY))
+		},
+	},
+
+	// The tree's frame has long horizontal lines of robots
+	"run": {
+		score: func(a *area, grid []int) int {
+			longest := 0
+
+			for y := 0; y < a.height; y++ {
+				run := 0
+
+				for x := 0; x < a.width; x++ {
+					if grid[y*a.width+x] > 0 {
+						run++
+						longest = max(longest, run)
+					} else {
+						run = 0
+					}
+				}
+			}
+
+			return longest
+		},
+		threshold: 10,
+	},
+
+	// The tree is one large group of robots touching each other
+	"component": {
+		score: func(a *area, grid []int) int {
+			largest := 0
+			seen := make([]bool, len(grid))
+
+			for start := range grid {
+				if grid[start] == 0 || seen[start] {
+					continue
+				}
+
+				size := 0
+				queue := []int{start}
+				seen[start] = true
+
+				for len(queue) > 0 {
+					i := queue[0]
+					queue = queue[1:]
+					size++
+
+					x, y := i%a.width, i/a.width
+					neighbors := []point{{x, y - 1}, {x + 1, y}, {x, y + 1}, {x - 1, y}}
+
+					for _, n := range neighbors {
+						if n.x < 0 || n.y < 0 || n.x >= a.width || n.y >= a.height {
+							continue
+						}
 
-		// Find the number of robots in each quadrant
-		// This is used to calculate the safety factor
-		robotsByQuadrant := area.robotsByQuadrant()
-		safetyFactor := 1
+						j := n.y*a.width + n.x
 
-		for _, robots := range robotsByQuadrant {
-			if len(robots) > 0 {
-				safetyFactor *= len(robots)
+						if grid[j] > 0 && !seen[j] {
+							seen[j] = true
+							queue = append(queue, j)
+						}
+					}
+				}
+
+				largest = max(largest, size)
 			}
+
+			return largest
+		},
+		threshold: 50,
+	},
+}
+
+// Score every tick up to `ticks` to find the one showing the christmas tree
+// Only the image of that tick is saved
+func detectTree(area *area, ticks int, detector treeDetector, outputFolder string) {
+	found, bestScore := 0, 0
+
+	for i := 1; i <= ticks; i++ {
+		frame := area.at(i)
+		score := detector.score(frame, frame.occupancy())
+
+		if detector.threshold != 0 && score >= detector.threshold {
+			found = i
+			break
 		}
 
-		img := area.ToImage()
+		if detector.threshold == 0 && (found == 0 || score > bestScore) {
+			found, bestScore = i, score
+		}
+	}
 
-		// Output filename is the number of ticks elapsed and the safety factor
-		f, err := os.Create(fmt.Sprintf("%s/%d - %d.png", outputFolder, i+1, safetyFactor))
+	if found == 0 {
+		fmt.Println("No tree found")
+		return
+	}
+
+	saveTree(area, found, outputFolder)
+}
+
+// Save the image of the tick showing the christmas tree, named the same way as generateFiles names them
+func saveTree(area *area, tick int, outputFolder string) {
+	frame := area.at(tick)
+	safetyFactor := frame.safetyFactor()
+
+	fmt.Println("Tree:", tick)
+
+	writeImage(fmt.Sprintf("%s/%d - %d.png", outputFolder, tick, safetyFactor), frame.ToImage())
+}
+
+// Frames are tiny and there are thousands of them, so favor speed over file size
+var pngEncoder = png.Encoder{CompressionLevel: png.BestSpeed}
+
+// Save an image to disk as a PNG
+func writeImage(filename string, img image.Image) {
+	f, err := os.Create(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	defer f.Close()
+
+	if err := pngEncoder.Encode(f, img); err != nil {
+		panic(err)
+	}
+}
 
-		if err != nil {
-			panic(err)
+// Playback delays in milliseconds, ordered from fastest to slowest
+var speeds = []int{0, 10, 25, 50, 100, 250, 500, 1000}
+
+// Characters standing in for half blocks, indexed by whether the top plot is occupied plus 2 if the bottom plot is occupied
+// Kept to ASCII since curses escapes multibyte characters unless it's the wide character build
+var blocks = []string{" ", "'", ",", ":"}
+
+// Animate the robots moving around the area in the terminal
+// Playback can be paused, stepped in either direction, sped up or slowed down, or jumped to a specific tick
+// Ticks wrap around at the period, since the robots are back where they started
+func animate() {
+	if cap(os.Args) < 5 {
+		fmt.Fprintf(os.Stderr, "Usage: %s animate width height [input-file]\n", os.Args[0])
+		os.Exit(-1)
+	}
+
+	width, err := strconv.Atoi(os.Args[2])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid width\n")
+		os.Exit(-1)
+	}
+
+	height, err := strconv.Atoi(os.Args[3])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid height\n")
+		os.Exit(-1)
+	}
+
+	start := newArea(width, height)
+	loadRobotsFromInput(start, os.Args[4])
+
+	period := start.period()
+
+	stdscr, err := goncurses.Init()
+	if err != nil {
+		panic(err)
+	}
+
+	defer goncurses.End()
+
+	goncurses.CBreak(true)
+	goncurses.Echo(false)
+	goncurses.Cursor(0)
+	stdscr.Keypad(true)
+
+	tick := 0
+	frame := start.at(tick)
+	speed := 3
+	paused := true
+
+	for {
+		displayArea(stdscr, frame, tick, period)
+
+		status := "Playing"
+		if paused {
+			status = "Paused"
 		}
 
-		png.Encode(f, img)
+		stdscr.Println(status, " Delay:", strconv.Itoa(speeds[speed])+"ms")
+		stdscr.Println("[space] pause  [left/right] step  [+/-] speed  [g] jump to tick  [q] quit")
+		stdscr.Refresh()
+
+		if paused {
+			stdscr.Timeout(-1)
+		} else {
+			stdscr.Timeout(speeds[speed])
+		}
+
+		switch key := stdscr.GetChar(); key {
+		case 'q':
+			return
+		case ' ':
+			paused = !paused
+		case goncurses.KEY_RIGHT, 'n':
+			paused = true
+			tick = (tick + 1) % period
+			frame.moveRobots()
+		case goncurses.KEY_LEFT, 'p':
+			paused = true
+			tick = mod(tick-1, period)
+			frame = start.at(tick)
+		case '+', '=':
+			speed = max(speed-1, 0)
+		case '-':
+			speed = min(speed+1, len(speeds)-1)
+		case 'g':
+			if n, ok := promptTick(stdscr, period); ok {
+				tick = n
+				frame = start.at(tick)
+				paused = true
+			}
+		case 0:
+			// Timed out waiting for a key, so advance to the next tick while playing
+			if !paused {
+				tick = (tick + 1) % period
+				frame.moveRobots()
+			}
+		}
+	}
+}
+
+// Draw the area with its status line
+// Each character covers two rows of plots, so the puzzle's area fits in a normal terminal
+func displayArea(stdscr *goncurses.Window, frame *area, tick, period int) {
+	grid := frame.occupancy()
+
+	stdscr.Clear()
+	stdscr.Println("Tick:", tick, "/", period, " Safety factor:", frame.safetyFactor())
+
+	for y := 0; y < frame.height; y += 2 {
+		var line strings.Builder
+
+		for x := 0; x < frame.width; x++ {
+			block := 0
+
+			if grid[y*frame.width+x] > 0 {
+				block++
+			}
+
+			if y+1 < frame.height && grid[(y+1)*frame.width+x] > 0 {
+				block += 2
+			}
+
+			line.WriteString(blocks[block])
+		}
+
+		stdscr.Println(line.String())
 	}
 }
 
+// Ask for a tick to jump to
+// Returns false if the entry isn't a number between 0 and one less than the period
+func promptTick(stdscr *goncurses.Window, period int) (int, bool) {
+	goncurses.Echo(true)
+	goncurses.Cursor(1)
+	defer goncurses.Echo(false)
+	defer goncurses.Cursor(0)
+
+	stdscr.Timeout(-1)
+	stdscr.Printf("Jump to tick (0-%d): ", period-1)
+
+	entry, err := stdscr.GetString(10)
+	if err != nil {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(entry))
+	if err != nil || n < 0 || n >= period {
+		return 0, false
+	}
+
+	return n, true
+}
+
 // Input file contains each robot patrolling the bathroom
 // Each line is a robot and
 func loadRobotsFromInput(area *area, filename string) {
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Example robots from the puzzle, patrolling an 11 by 7 area
+const example = `p=0,4 v=3,-3
+p=6,3 v=-1,-3
+p=10,3 v=-1,2
+p=2,0 v=2,-1
+p=0,0 v=1,3
+p=3,0 v=-2,-2
+p=7,6 v=-1,-3
+p=3,0 v=-1,-2
+p=9,3 v=2,3
+p=7,3 v=-1,2
+p=2,4 v=2,-3
+p=9,5 v=-3,-3
+`
+
+func TestSafetyFactorExample(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "input.txt")
+
+	if err := os.WriteFile(filename, []byte(example), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newArea(11, 7)
+	loadRobotsFromInput(a, filename)
+
+	if len(a.robots) != 12 {
+		t.Fatalf("expected 12 robots, got %d", len(a.robots))
+	}
+
+	if got := a.at(100).safetyFactor(); got != 12 {
+		t.Errorf("expected safety factor 12 after 100 ticks, got %d", got)
+	}
+}
+
+func TestSafetyFactorEmptyQuadrant(t *testing.T) {
+	a := newArea(11, 7)
+
+	// Two robots in the top left, one in the top right and one in the bottom left, leaving the bottom right empty
+	// Robots on the middle row and column don't count towards any quadrant
+	a.robots = []robot{
+		{point{0, 0}, vec{0, 0}},
+		{point{1, 1}, vec{0, 0}},
+		{point{8, 1}, vec{0, 0}},
+		{point{2, 5}, vec{0, 0}},
+		{point{5, 5}, vec{0, 0}},
+		{point{9, 3}, vec{0, 0}},
+	}
+
+	if got := a.safetyFactor(); got != 0 {
+		t.Errorf("expected safety factor 0 with an empty quadrant, got %d", got)
+	}
+}
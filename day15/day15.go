@@ -5,8 +5,17 @@ package main
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"math/rand/v2"
 	"os"
 	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gbin/goncurses"
 )
 
 type point struct {
@@ -21,6 +30,20 @@ type vec struct {
 	x, y int
 }
 
+// Character used for the movement in the puzzle input
+func (v vec) String() string {
+	switch v {
+	case Up:
+		return "^"
+	case Right:
+		return ">"
+	case Down:
+		return "v"
+	default:
+		return "<"
+	}
+}
+
 var Up = vec{0, -1}
 var Right = vec{1, 0}
 var Down = vec{0, 1}
@@ -36,79 +59,959 @@ const (
 	BoxRight
 )
 
-type warehouse map[point]int
+// Spaces of the warehouse stored row by row
+// Boxes are kept separately, with each space recording which box covers it
+type warehouse struct {
+	width, height int
+	spaces        []int // Wall or Empty
+	boxAt         []int // Id of the box covering each space, or -1
+	boxes         []box // Indexed by id
+	gps           gpsRule
+
+	// Reused between moves to collect the ids of the boxes being pushed
+	pushed []int
+}
+
+// A box in the warehouse, which can cover any shape of spaces
+type box struct {
+	id    int
+	pos   point // The box's first space in reading order
+	shape []vec // Offsets from pos of every space the box covers
+	glyph byte  // Character the box is drawn with, or 0 for puzzle boxes drawn as O or []
+}
+
+// Shapes of the boxes in the puzzle
+var (
+	singleWideShape = []vec{{0, 0}}
+	doubleWideShape = []vec{{0, 0}, {1, 0}}
+)
+
+// Calculates the GPS coordinate of a box
+type gpsRule func(b box) int
+
+// GPS coordinate measured from the top and left edges of the map to the closest edge of the box
+// The puzzle weights rows by 100 and columns by 1
+func closestEdgeGPS(rowWeight, colWeight int) gpsRule {
+	return func(b box) int {
+		top, left := b.pos.y, b.pos.x
+
+		for _, offset := range b.shape {
+			top = min(top, b.pos.y+offset.y)
+			left = min(left, b.pos.x+offset.x)
+		}
 
-func newWarehouse() warehouse {
-	return make(map[point]int)
+		return rowWeight*top + colWeight*left
+	}
+}
+
+// GPS rule used by the puzzle
+var puzzleGPS = closestEdgeGPS(100, 1)
+
+// Create an empty warehouse that scores boxes with the given GPS rule
+func newWarehouse(gps gpsRule) *warehouse {
+	return &warehouse{gps: gps}
 }
 
 // Populate walls and boxes in a warehouse from puzzle input
 // For Part 2, the doubleWide argument is set which causes all walls and boxes to be twice as wide
-func (wh warehouse) populate(input string, doubleWide bool) point {
-	y := 0
-	x := 0
+// Besides the puzzle's O boxes, neighboring spaces with the same capital letter form a single box of any shape
+func (wh *warehouse) populate(input string, doubleWide bool) point {
+	// Empty line detected, end of map
+	mapInput, _, _ := strings.Cut(input, "\n\n")
+	lines := strings.Split(strings.TrimRight(mapInput, "\n"), "\n")
+
+	scale := 1
+	if doubleWide {
+		scale = 2
+	}
+
+	// Rows can be different lengths, so the grid is as wide as the longest
+	longest := 0
+	for _, line := range lines {
+		longest = max(longest, len(line))
+	}
+
+	wh.width = longest * scale
+	wh.height = len(lines)
+	wh.spaces = make([]int, wh.width*wh.height)
+	wh.boxAt = make([]int, wh.width*wh.height)
+	wh.boxes = make([]box, 0)
+
+	// Anything past the end of a shorter row is treated as wall
+	for i := range wh.spaces {
+		wh.spaces[i] = Wall
+		wh.boxAt[i] = -1
+	}
 
 	var robotPos point
+	letters := make(map[point]byte)
+
+	for y, line := range lines {
+		for i := 0; i < len(line); i++ {
+			pt := point{i * scale, y}
+			kind := Empty
+
+			switch c := line[i]; {
+			case c == '#':
+				kind = Wall
+			case c == '@':
+				// @ indicates the robot's starting position
+				// We'll track his movement outside the map
+				robotPos = pt
+			case c == 'O':
+				shape := singleWideShape
+				if doubleWide {
+					shape = doubleWideShape
+				}
 
-	for _, c := range input {
-		if c == '\n' {
-			// Empty line detected, end of map
-			if x == 0 {
-				break
+				wh.addBox(pt, shape, 0)
+			case c >= 'A' && c <= 'Z':
+				for dx := 0; dx < scale; dx++ {
+					letters[pt.Add(vec{dx, 0})] = c
+				}
+			}
+
+			for dx := 0; dx < scale; dx++ {
+				wh.spaces[wh.index(pt.Add(vec{dx, 0}))] = kind
+			}
+		}
+	}
+
+	// Flood fill each group of lettered spaces into a box, in reading order so the box's first space is found first
+	for y := 0; y < wh.height; y++ {
+		for x := 0; x < wh.width; x++ {
+			start := point{x, y}
+			letter, ok := letters[start]
+
+			if !ok {
+				continue
+			}
+
+			shape := make([]vec, 0)
+			queue := []point{start}
+			delete(letters, start)
+
+			for len(queue) > 0 {
+				pt := queue[0]
+				queue = queue[1:]
+
+				shape = append(shape, vec{pt.x - start.x, pt.y - start.y})
+
+				for _, dir := range []vec{Up, Right, Down, Left} {
+					next := pt.Add(dir)
+
+					if letters[next] == letter {
+						delete(letters, next)
+						queue = append(queue, next)
+					}
+				}
+			}
+
+			wh.addBox(start, shape, letter)
+		}
+	}
+
+	return robotPos
+}
+
+// Add a box covering the given shape of spaces
+func (wh *warehouse) addBox(pos point, shape []vec, glyph byte) {
+	b := box{len(wh.boxes), pos, shape, glyph}
+	wh.boxes = append(wh.boxes, b)
+
+	for _, offset := range shape {
+		wh.boxAt[wh.index(pos.Add(offset))] = b.id
+	}
+}
+
+func (wh *warehouse) index(pt point) int {
+	return pt.y*wh.width + pt.x
+}
+
+// Returns the kind of space at the given point, which is Box for any space covered by a box
+// Anything outside the warehouse is treated as a wall
+func (wh *warehouse) get(pt point) int {
+	if pt.x < 0 || pt.y < 0 || pt.x >= wh.width || pt.y >= wh.height {
+		return Wall
+	}
+
+	if wh.boxAt[wh.index(pt)] >= 0 {
+		return Box
+	}
+
+	return wh.spaces[wh.index(pt)]
+}
+
+// Returns the box covering the given point
+func (wh *warehouse) boxAtPoint(pt point) (box, bool) {
+	if wh.get(pt) != Box {
+		return box{}, false
+	}
+
+	return wh.boxes[wh.boxAt[wh.index(pt)]], true
+}
+
+// Character drawn for the space at pt, which the box covers
+func (b box) glyphAt(pt point) byte {
+	switch {
+	case b.glyph != 0:
+		return b.glyph
+	case len(b.shape) == 2 && pt == b.pos:
+		return '['
+	case len(b.shape) == 2:
+		return ']'
+	default:
+		return 'O'
+	}
+}
+
+// Copy the warehouse so it can be changed without affecting the original
+// Box shapes never change, so they're shared with the original
+func (wh *warehouse) clone() *warehouse {
+	return &warehouse{
+		width:  wh.width,
+		height: wh.height,
+		spaces: wh.spaces,
+		boxAt:  slices.Clone(wh.boxAt),
+		boxes:  slices.Clone(wh.boxes),
+		gps:    wh.gps,
+	}
+}
+
+// Adds the box covering the given point to the boxes being pushed
+// Returns false if the point is a wall and nothing can be pushed
+func (wh *warehouse) collect(pt point) bool {
+	switch wh.get(pt) {
+	case Wall:
+		return false
+	case Empty:
+		return true
+	}
+
+	if id := wh.boxAt[wh.index(pt)]; !slices.Contains(wh.pushed, id) {
+		wh.pushed = append(wh.pushed, id)
+	}
+
+	return true
+}
+
+// Tries to make space at the given point for the robot to move into
+// Every box that would be pushed is collected first, then they're all moved together if none of them are blocked
+// Returns if the space is now open for the robot to move to
+func (wh *warehouse) tryMove(pt point, dir vec) bool {
+	wh.pushed = wh.pushed[:0]
+
+	if !wh.collect(pt) {
+		return false
+	}
+
+	// Boxes are added while looping, as each box can push the boxes in front of any of its spaces
+	for i := 0; i < len(wh.pushed); i++ {
+		b := wh.boxes[wh.pushed[i]]
+
+		for _, offset := range b.shape {
+			ahead := b.pos.Add(offset).Add(dir)
+
+			// Spaces the box already covers will be vacated as it moves
+			if wh.get(ahead) == Box && wh.boxAt[wh.index(ahead)] == b.id {
+				continue
+			}
+
+			if !wh.collect(ahead) {
+				return false
+			}
+		}
+	}
+
+	// Clear every box before moving them, so boxes moving into each other's spaces aren't overwritten
+	for _, id := range wh.pushed {
+		b := wh.boxes[id]
+
+		for _, offset := range b.shape {
+			wh.boxAt[wh.index(b.pos.Add(offset))] = -1
+		}
+	}
+
+	for _, id := range wh.pushed {
+		b := &wh.boxes[id]
+		b.pos = b.pos.Add(dir)
+
+		for _, offset := range b.shape {
+			wh.boxAt[wh.index(b.pos.Add(offset))] = id
+		}
+	}
+
+	return true
+}
+
+// Generate a displayable map of the warehouse
+func (wh *warehouse) String() string {
+	var str strings.Builder
+
+	for y := 0; y < wh.height; y++ {
+		for x := 0; x < wh.width; x++ {
+			pt := point{x, y}
+
+			switch wh.get(pt) {
+			case Empty:
+				str.WriteByte('.')
+			case Wall:
+				str.WriteByte('#')
+			case Box:
+				b, _ := wh.boxAtPoint(pt)
+				str.WriteByte(b.glyphAt(pt))
+			}
+		}
+
+		str.WriteByte('\n')
+	}
+
+	return str.String()
+}
+
+// Calculate the sum of all box GPS coordinates
+// By default, GPS coordinates of a box are (Y * 100) + X of its closest edge
+func (wh *warehouse) gpsSum() int {
+	gpsSum := 0
+
+	for _, b := range wh.boxes {
+		gpsSum += wh.gps(b)
+	}
+
+	return gpsSum
+}
+
+// Generate a displayable map of the warehouse with the robot shown as an @
+func (wh *warehouse) stringWithRobot(robot point) string {
+	lines := strings.Split(wh.String(), "\n")
+	line := []byte(lines[robot.y])
+	line[robot.x] = '@'
+	lines[robot.y] = string(line)
+
+	return strings.Join(lines, "\n")
+}
+
+func main() {
+	if cap(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s [input-file]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [input-file] interactive [single/double]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [input-file] replay [single/double] [move]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [input-file] gif [single/double] [output-file]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [input-file] check [single/double] [trials] [seed]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [input-file] target [single/double] [target-file] [max-moves]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [input-file] maxgps/mingps [single/double] [moves]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [input-file] gps [single/double] [row-weight] [col-weight]\n", os.Args[0])
+		os.Exit(-1)
+	}
+
+	inputContents, err := os.ReadFile(os.Args[1])
+	if err != nil {
+		panic(err)
+	}
+
+	movements := movementsFromInput(string(inputContents))
+
+	if len(os.Args) > 2 {
+		doubleWide := len(os.Args) > 3 && os.Args[3] == "double"
+
+		wh := newWarehouse(puzzleGPS)
+		robotPos := wh.populate(string(inputContents), doubleWide)
+
+		switch os.Args[2] {
+		case "interactive":
+			// Printed once curses has ended, in the same format as the input
+			moves := interactive(wh, robotPos)
+			fmt.Println(movementsString(moves))
+		case "replay":
+			// Without a move, every move is printed
+			only := -1
+			if len(os.Args) > 4 {
+				if only, err = strconv.Atoi(os.Args[4]); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid move\n")
+					os.Exit(-1)
+				}
+			}
+
+			printReplay(wh, robotPos, movements, only)
+		case "gif":
+			if len(os.Args) < 5 {
+				fmt.Fprintf(os.Stderr, "Missing output-file\n")
+				os.Exit(-1)
+			}
+
+			writeGif(wh, robotPos, movements, os.Args[4])
+		case "check":
+			trials := 100
+			if len(os.Args) > 4 {
+				if trials, err = strconv.Atoi(os.Args[4]); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid trials\n")
+					os.Exit(-1)
+				}
+			}
+
+			seed := rand.Uint64()
+			if len(os.Args) > 5 {
+				if seed, err = strconv.ParseUint(os.Args[5], 10, 64); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid seed\n")
+					os.Exit(-1)
+				}
+			}
+
+			checkAgainstReference(wh, robotPos, trials, seed)
+		case "target":
+			if len(os.Args) < 5 {
+				fmt.Fprintf(os.Stderr, "Missing target-file\n")
+				os.Exit(-1)
+			}
+
+			targetContents, err := os.ReadFile(os.Args[4])
+			if err != nil {
+				panic(err)
+			}
+
+			target := newWarehouse(puzzleGPS)
+			target.populate(string(targetContents), doubleWide)
+
+			// Without a limit, the search continues until every reachable layout is tried
+			maxMoves := -1
+			if len(os.Args) > 5 {
+				if maxMoves, err = strconv.Atoi(os.Args[5]); err != nil {
+					fmt.Fprintf(os.Stderr, "Invalid max-moves\n")
+					os.Exit(-1)
+				}
+			}
+
+			solveTarget(wh, robotPos, target, maxMoves)
+		case "maxgps", "mingps":
+			if len(os.Args) < 5 {
+				fmt.Fprintf(os.Stderr, "Missing moves\n")
+				os.Exit(-1)
+			}
+
+			maxMoves, err := strconv.Atoi(os.Args[4])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid moves\n")
+				os.Exit(-1)
+			}
+
+			solveGPS(wh, robotPos, maxMoves, os.Args[2] == "maxgps")
+		case "gps":
+			if len(os.Args) < 6 {
+				fmt.Fprintf(os.Stderr, "Missing row-weight or col-weight\n")
+				os.Exit(-1)
+			}
+
+			rowWeight, err := strconv.Atoi(os.Args[4])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid row-weight\n")
+				os.Exit(-1)
+			}
+
+			colWeight, err := strconv.Atoi(os.Args[5])
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid col-weight\n")
+				os.Exit(-1)
+			}
+
+			// Same moves as the puzzle, scored with the given weights instead of the puzzle's
+			weighted := newWarehouse(closestEdgeGPS(rowWeight, colWeight))
+			robotPos := weighted.populate(string(inputContents), doubleWide)
+
+			solve(weighted, robotPos, movements)
+		}
+
+		return
+	}
+
+	// Part 1
+	{
+		wh := newWarehouse(puzzleGPS)
+		robotPos := wh.populate(string(inputContents), false)
+		solve(wh, robotPos, movements)
+	}
+
+	// Part 2
+	{
+		wh := newWarehouse(puzzleGPS)
+		robotPos := wh.populate(string(inputContents), true)
+
+		solve(wh, robotPos, movements)
+	}
+}
+
+// Moves the robot one space in the given direction, pushing any boxes in the way
+// Returns the robot's new position, which is unchanged if it was blocked
+func moveRobot(wh *warehouse, robot point, dir vec) point {
+	targetPos := robot.Add(dir)
+
+	if wh.tryMove(targetPos, dir) {
+		return targetPos
+	}
+
+	return robot
+}
+
+// Performs all robot movements in a warehouse and returns the GPS Sum of all boxes after they're moved
+func solve(wh *warehouse, robot point, movements []vec) {
+	for _, movement := range movements {
+		robot = moveRobot(wh, robot, movement)
+	}
+
+	fmt.Println("GPS Sum:", wh.gpsSum())
+}
+
+// Performs all robot movements, printing the warehouse after each one the same way the puzzle does
+// If only is 0 or more, then just the warehouse after that many moves is printed
+func printReplay(wh *warehouse, robot point, movements []vec, only int) {
+	if only <= 0 {
+		fmt.Println("Initial state:")
+		fmt.Println(wh.stringWithRobot(robot))
+	}
+
+	for i, movement := range movements {
+		if only >= 0 && i >= only {
+			break
+		}
+
+		robot = moveRobot(wh, robot, movement)
+
+		if only < 0 || i+1 == only {
+			fmt.Printf("Move %s:\n", movement)
+			fmt.Println(wh.stringWithRobot(robot))
+		}
+	}
+}
+
+// Size in pixels of each space in the warehouse when drawn in a GIF
+const spaceSize = 4
+
+// Colors of each kind of space, plus the robot
+// Boxes alternate between two shades so neighboring boxes can be told apart
+var gifPalette = color.Palette{
+	color.RGBA{0, 0, 0, 255},       // Empty
+	color.RGBA{96, 96, 96, 255},    // Wall
+	color.RGBA{200, 140, 60, 255},  // Box
+	color.RGBA{160, 100, 40, 255},  // Box, alternate shade
+	color.RGBA{255, 255, 255, 255}, // Robot
+}
+
+// Draw the warehouse as an image for a GIF frame
+func (wh *warehouse) toImage(robot point) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, wh.width*spaceSize, wh.height*spaceSize), gifPalette)
+
+	for y := 0; y < wh.height; y++ {
+		for x := 0; x < wh.width; x++ {
+			pt := point{x, y}
+
+			var index uint8
+
+			switch wh.get(pt) {
+			case Wall:
+				index = 1
+			case Box:
+				b, _ := wh.boxAtPoint(pt)
+				index = 2 + uint8(b.id%2)
+			}
+
+			if pt == robot {
+				index = 4
+			}
+
+			for dy := 0; dy < spaceSize; dy++ {
+				for dx := 0; dx < spaceSize; dx++ {
+					img.SetColorIndex(x*spaceSize+dx, y*spaceSize+dy, index)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// Performs all robot movements, saving every step as a frame of an animated GIF
+func writeGif(wh *warehouse, robot point, movements []vec, filename string) {
+	anim := gif.GIF{}
+
+	addFrame := func() {
+		anim.Image = append(anim.Image, wh.toImage(robot))
+		anim.Delay = append(anim.Delay, 2)
+	}
+
+	addFrame()
+
+	for _, movement := range movements {
+		robot = moveRobot(wh, robot, movement)
+		addFrame()
+	}
+
+	f, err := os.Create(filename)
+	if err != nil {
+		panic(err)
+	}
+
+	defer f.Close()
+
+	if err := gif.EncodeAll(f, &anim); err != nil {
+		panic(err)
+	}
+}
+
+// State of the warehouse before a move, kept so the move can be undone
+type snapshot struct {
+	wh    *warehouse
+	robot point
+}
+
+// Move the robot around the warehouse with the arrow keys
+// Every move can be undone, and the moves made are returned on quitting
+func interactive(wh *warehouse, robot point) []vec {
+	stdscr, err := goncurses.Init()
+	if err != nil {
+		panic(err)
+	}
+	defer goncurses.End()
+
+	goncurses.CBreak(true)
+	goncurses.Echo(false)
+	goncurses.Cursor(0)
+	stdscr.Keypad(true)
+
+	history := make([]snapshot, 0)
+	moves := make([]vec, 0)
+
+	for {
+		stdscr.Clear()
+		stdscr.Println("Moves:", len(moves), " GPS Sum:", wh.gpsSum())
+		stdscr.Println("[arrows] move  [u] undo  [q] quit")
+		stdscr.Println(wh.stringWithRobot(robot))
+		stdscr.Refresh()
+
+		var dir vec
+
+		switch stdscr.GetChar() {
+		case goncurses.KEY_UP:
+			dir = Up
+		case goncurses.KEY_RIGHT:
+			dir = Right
+		case goncurses.KEY_DOWN:
+			dir = Down
+		case goncurses.KEY_LEFT:
+			dir = Left
+		case 'u':
+			if len(history) > 0 {
+				last := history[len(history)-1]
+				wh, robot = last.wh, last.robot
+
+				history = history[:len(history)-1]
+				moves = moves[:len(moves)-1]
 			}
 
-			x = 0
-			y++
+			continue
+		case 'q':
+			return moves
+		default:
+			continue
+		}
+
+		// Moves that bump into a wall are still recorded, since they're part of the movement list
+		history = append(history, snapshot{wh.clone(), robot})
+		moves = append(moves, dir)
+
+		robot = moveRobot(wh, robot, dir)
+	}
+}
+
+// Format movement commands the same way as the input file
+func movementsString(movements []vec) string {
+	var str strings.Builder
+
+	for _, movement := range movements {
+		str.WriteString(movement.String())
+	}
+
+	return str.String()
+}
+
+// Parse input file contents to retrieve all movement commands for the robot
+func movementsFromInput(input string) []vec {
+	movements := make([]vec, 0)
+
+	rx := regexp.MustCompile(`[<^>v]+`)
+	matches := rx.FindAllString(input, -1)
 
+	for _, match := range matches {
+		for _, c := range match {
+			var dir vec
+
+			switch c {
+			case '^':
+				dir = Up
+			case '>':
+				dir = Right
+			case 'v':
+				dir = Down
+			case '<':
+				dir = Left
+			}
+
+			movements = append(movements, dir)
+		}
+	}
+
+	return movements
+}
+
+// A state reached while searching for movements
+type searchNode struct {
+	wh     *warehouse // Released once the node's neighbors have been searched
+	robot  point
+	parent int // Index of the node this was reached from, or -1 for the starting state
+	move   vec // Movement that reached this node from its parent
+	depth  int
+}
+
+// Breadth-first search over every arrangement of the robot and boxes
+// Arrangements that look the same are only searched once, even if identical boxes have swapped places
+type searcher struct {
+	nodes []searchNode
+	seen  map[string]bool
+}
+
+func newSearcher(wh *warehouse, robot point) *searcher {
+	s := &searcher{
+		nodes: []searchNode{{wh.clone(), robot, -1, vec{}, 0}},
+		seen:  make(map[string]bool),
+	}
+
+	s.seen[wh.stringWithRobot(robot)] = true
+
+	return s
+}
+
+// Search outward from the starting state one movement at a time, up to maxMoves movements if it isn't negative
+// Each movement uses the same rules as tryMove
+// States where prune returns true aren't searched any further
+// visit is called with the index of each state reached, and returns true to stop searching
+// Returns the index of the state the search stopped at, or -1 if it never stopped
+func (s *searcher) run(maxMoves int, prune func(wh *warehouse) bool, visit func(i int) bool) int {
+	if visit(0) {
+		return 0
+	}
+
+	for i := 0; i < len(s.nodes); i++ {
+		node := s.nodes[i]
+
+		if maxMoves >= 0 && node.depth >= maxMoves {
 			continue
 		}
 
-		var singleWideKind int
-		var leftKind int
-		var rightKind int
-
-		switch c {
-		case '#':
-			singleWideKind = Wall
-			leftKind = Wall
-			rightKind = Wall
-		case '@':
-			//kind = Robot
-			// @ indicates the robot's starting position
-			// We'll track his movement outside the map
-			robotPos = point{x, y}
-
-			singleWideKind = Empty
-			leftKind = Empty
-			rightKind = Empty
-		case 'O':
-			singleWideKind = Box
-			leftKind = BoxLeft
-			rightKind = BoxRight
-
-		case '.':
-			singleWideKind = Empty
-			leftKind = Empty
-			rightKind = Empty
+		for _, dir := range []vec{Up, Right, Down, Left} {
+			wh := node.wh.clone()
+			robot := moveRobot(wh, node.robot, dir)
+
+			key := wh.stringWithRobot(robot)
+			if s.seen[key] {
+				continue
+			}
+
+			s.seen[key] = true
+
+			if prune(wh) {
+				continue
+			}
+
+			s.nodes = append(s.nodes, searchNode{wh, robot, i, dir, node.depth + 1})
+
+			if visit(len(s.nodes) - 1) {
+				return len(s.nodes) - 1
+			}
 		}
 
-		if doubleWide {
-			wh[point{x, y}] = leftKind
-			wh[point{x + 1, y}] = rightKind
+		s.nodes[i].wh = nil
+	}
 
-			x += 2
-		} else {
-			wh[point{x, y}] = singleWideKind
-			x++
+	return -1
+}
+
+// Returns the movements leading from the starting state to the state at index i
+func (s *searcher) path(i int) []vec {
+	movements := make([]vec, 0)
+
+	for ; s.nodes[i].parent >= 0; i = s.nodes[i].parent {
+		movements = append(movements, s.nodes[i].move)
+	}
+
+	slices.Reverse(movements)
+
+	return movements
+}
+
+// Returns true if the box can never be pushed again, no matter where the robot or other boxes are
+// A box can only be pushed in a direction if none of the spaces ahead of it are walls,
+// and the robot has somewhere to stand behind it, which may be behind a chain of other boxes
+func (wh *warehouse) frozen(b box) bool {
+	for _, dir := range []vec{Up, Right, Down, Left} {
+		behind := vec{-dir.x, -dir.y}
+		blocked, pushable := false, false
+
+		for _, offset := range b.shape {
+			pt := b.pos.Add(offset)
+
+			if ahead := pt.Add(dir); wh.get(ahead) == Wall {
+				blocked = true
+			}
+
+			if back := pt.Add(behind); !slices.Contains(b.shape, vec{back.x - b.pos.x, back.y - b.pos.y}) && wh.get(back) != Wall {
+				pushable = true
+			}
+		}
+
+		if !blocked && pushable {
+			return false
 		}
 	}
 
-	return robotPos
+	return true
+}
+
+// Returns true if the target has a box of the same shape in the same place
+func (wh *warehouse) hasBox(b box) bool {
+	other, ok := wh.boxAtPoint(b.pos)
+
+	return ok && other.pos == b.pos && other.glyphAt(b.pos) == b.glyphAt(b.pos) && slices.Equal(other.shape, b.shape)
+}
+
+// Find the fewest movements that push every box into the layout of the target warehouse
+// Layouts with a box frozen outside of the target, such as in a corner, can never be solved and aren't searched
+// The movements are printed in the same format as the input, with everything else printed to stderr
+func solveTarget(wh *warehouse, robot point, target *warehouse, maxMoves int) {
+	goal := target.String()
+
+	deadlocked := func(wh *warehouse) bool {
+		for _, b := range wh.boxes {
+			if wh.frozen(b) && !target.hasBox(b) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	s := newSearcher(wh, robot)
+
+	found := s.run(maxMoves, deadlocked, func(i int) bool {
+		return s.nodes[i].wh.String() == goal
+	})
+
+	fmt.Fprintln(os.Stderr, "States:", len(s.seen))
+
+	if found < 0 {
+		fmt.Fprintln(os.Stderr, "No solution")
+		os.Exit(1)
+	}
+
+	fmt.Fprintln(os.Stderr, "Moves:", s.nodes[found].depth)
+	fmt.Println(movementsString(s.path(found)))
+}
+
+// Find the movements within maxMoves that give the highest or lowest GPS sum
+// When several give the same sum, the fewest movements are chosen
+// The movements are printed in the same format as the input, with everything else printed to stderr
+func solveGPS(wh *warehouse, robot point, maxMoves int, highest bool) {
+	s := newSearcher(wh, robot)
+	best, bestSum := 0, wh.gpsSum()
+
+	s.run(maxMoves, func(*warehouse) bool { return false }, func(i int) bool {
+		sum := s.nodes[i].wh.gpsSum()
+
+		if (highest && sum > bestSum) || (!highest && sum < bestSum) {
+			best, bestSum = i, sum
+		}
+
+		return false
+	})
+
+	fmt.Fprintln(os.Stderr, "States:", len(s.seen))
+	fmt.Fprintln(os.Stderr, "Moves:", s.nodes[best].depth)
+	fmt.Fprintln(os.Stderr, "GPS Sum:", bestSum)
+	fmt.Println(movementsString(s.path(best)))
+}
+
+// Number of random movements made in each trial when checking against the reference warehouse
+const checkMovements = 1000
+
+// Make random movements in copies of the warehouse and the reference map-backed warehouse
+// Stops at the first movement where the two disagree, printing the movements that led to it
+func checkAgainstReference(wh *warehouse, robot point, trials int, seed uint64) {
+	rng := rand.New(rand.NewPCG(seed, seed))
+	dirs := []vec{Up, Right, Down, Left}
+
+	for trial := 0; trial < trials; trial++ {
+		grid := wh.clone()
+		gridRobot := robot
+
+		reference := newMapWarehouse(wh)
+		referenceRobot := robot
+
+		movements := make([]vec, 0, checkMovements)
+
+		for i := 0; i < checkMovements; i++ {
+			dir := dirs[rng.IntN(len(dirs))]
+			movements = append(movements, dir)
+
+			gridRobot = moveRobot(grid, gridRobot, dir)
+
+			if target := referenceRobot.Add(dir); reference.tryMove(target, dir) {
+				referenceRobot = target
+			}
+
+			if gridRobot != referenceRobot || grid.String() != reference.String() {
+				fmt.Printf("Trial %d (seed %d) differs after %d movements:\n", trial+1, seed, i+1)
+				fmt.Println(movementsString(movements))
+				fmt.Println("Expected:")
+				fmt.Print(reference.String())
+				fmt.Println("Actual:")
+				fmt.Print(grid.String())
+				os.Exit(1)
+			}
+		}
+	}
+
+	fmt.Println("All", trials, "trials match")
+}
+
+// Original map-backed warehouse, kept as a reference to check the grid-backed warehouse against
+type mapWarehouse map[point]int
+
+// Copy the spaces of a warehouse into a map-backed warehouse
+// Only the puzzle's single and double-wide boxes can be copied
+func newMapWarehouse(wh *warehouse) mapWarehouse {
+	m := make(mapWarehouse)
+
+	for y := 0; y < wh.height; y++ {
+		for x := 0; x < wh.width; x++ {
+			pt := point{x, y}
+			kind := wh.get(pt)
+
+			if b, ok := wh.boxAtPoint(pt); ok {
+				switch b.glyphAt(pt) {
+				case '[':
+					kind = BoxLeft
+				case ']':
+					kind = BoxRight
+				}
+			}
+
+			m[pt] = kind
+		}
+	}
+
+	return m
 }
 
 // Determine if the robot can move to the point given
 // If the `adjust` parameter is set, boxes will be moved in the warehouse. Else it just determines that they CAN be moved
-func (wh warehouse) canMove(pt point, dir vec, adjust bool) bool {
+func (wh mapWarehouse) canMove(pt point, dir vec, adjust bool) bool {
 	if target, ok := wh[pt]; ok {
 		switch target {
 		case Wall:
@@ -181,7 +1084,7 @@ func (wh warehouse) canMove(pt point, dir vec, adjust bool) bool {
 
 // Tries to make space at the given point for the robot to move into
 // Returns if the space is now open for the robot to move to
-func (wh warehouse) tryMove(pt point, dir vec) bool {
+func (wh mapWarehouse) tryMove(pt point, dir vec) bool {
 	// First check if the robot can move to the space
 	if wh.canMove(pt, dir, false) {
 		// After verifying that it can, commit any box movements to allow the robot to move
@@ -191,42 +1094,8 @@ func (wh warehouse) tryMove(pt point, dir vec) bool {
 	return false
 }
 
-// Returns the points of all boxes in the warehouse
-func (wh warehouse) allBoxPoints() []point {
-	pts := make([]point, 0)
-
-	y := 0
-	x := 0
-
-	for {
-		x = 0
-
-		if _, ok := wh[point{x, y}]; !ok {
-			break
-		}
-
-		for {
-			pt := point{x, y}
-			kind, ok := wh[pt]
-			if !ok {
-				break
-			}
-
-			if kind == Box || kind == BoxLeft {
-				pts = append(pts, pt)
-			}
-
-			x++
-		}
-
-		y++
-	}
-
-	return pts
-}
-
 // Generate a displayable map of the warehouse
-func (wh warehouse) String() string {
+func (wh mapWarehouse) String() string {
 	var str string
 	y := 0
 	x := 0
@@ -268,83 +1137,3 @@ func (wh warehouse) String() string {
 
 	return str
 }
-
-func main() {
-	if cap(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [input-file]\n", os.Args[0])
-		os.Exit(-1)
-	}
-
-	inputContents, err := os.ReadFile(os.Args[1])
-	if err != nil {
-		panic(err)
-	}
-
-	movements := movementsFromInput(string(inputContents))
-
-	// Part 1
-	{
-		wh := newWarehouse()
-		robotPos := wh.populate(string(inputContents), false)
-		solve(wh, robotPos, movements)
-	}
-
-	// Part 2
-	{
-		wh := newWarehouse()
-		robotPos := wh.populate(string(inputContents), true)
-
-		solve(wh, robotPos, movements)
-	}
-}
-
-// Performs all robot movements in a warehouse and returns the GPS Sum of all boxes after they're moved
-func solve(wh warehouse, robot point, movements []vec) {
-	for _, movement := range movements {
-		targetPos := robot.Add(movement)
-
-		if wh.tryMove(targetPos, movement) {
-			robot = targetPos
-		}
-	}
-
-	// Calculate the sum of all box GPS coordinates
-	// GPS coordinates of a box are (Y * 100) + X
-	gpsSum := 0
-	boxPoints := wh.allBoxPoints()
-
-	for _, boxPoint := range boxPoints {
-		gpsSum += (100 * boxPoint.y) + boxPoint.x
-	}
-
-	fmt.Println("GPS Sum:", gpsSum)
-}
-
-// Parse input file contents to retrieve all movement commands for the robot
-func movementsFromInput(input string) []vec {
-	movements := make([]vec, 0)
-
-	rx := regexp.MustCompile(`[<^>v]+`)
-	matches := rx.FindAllString(input, -1)
-
-	for _, match := range matches {
-		for _, c := range match {
-			var dir vec
-
-			switch c {
-			case '^':
-				dir = Up
-			case '>':
-				dir = Right
-			case 'v':
-				dir = Down
-			case '<':
-				dir = Left
-			}
-
-			movements = append(movements, dir)
-		}
-	}
-
-	return movements
-}
@@ -1,3 +1,5 @@
 module github.com/cwmiller/advent-of-code-2024/day15
 
 go 1.23.2
+
+require github.com/gbin/goncurses v0.0.0-20240517145248-be6a464272ae
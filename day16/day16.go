@@ -4,8 +4,12 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/albertorestifo/dijkstra"
@@ -130,20 +134,160 @@ type Tile struct {
 	pos  Point
 }
 
+// Costs of moving the reindeer through the maze
+type CostModel struct {
+	step    int           // Moving forward one tile
+	turn    int           // Rotating 90 degrees before moving
+	reverse int           // Rotating 180 degrees before moving
+	weights map[Point]int // Extra cost of entering specific tiles
+	anyEnd  bool          // Whether the end can be reached facing any direction, rather than only facing up
+}
+
+// Scoring rules of the original solver
+// Turning around costs the same as a single turn, and the end has to be reached facing up
+func DefaultCostModel() CostModel {
+	return CostModel{
+		step:    1,
+		turn:    1000,
+		reverse: 1000,
+		weights: make(map[Point]int),
+	}
+}
+
+// Scoring rules as the puzzle states them
+// Turning around is two 90 degree rotations, and the reindeer can reach the end facing any direction
+func PuzzleCostModel() CostModel {
+	costs := DefaultCostModel()
+	costs.reverse = 2 * costs.turn
+	costs.anyEnd = true
+
+	return costs
+}
+
+// Cost of moving onto the tile at pos, heading in dir after previously heading in from
+func (c CostModel) MoveCost(from, dir Vec, pos Point) int {
+	cost := c.step + c.weights[pos]
+
+	switch dir {
+	case from:
+	case from.Clockwise(), from.CounterClockwise():
+		cost += c.turn
+	default:
+		cost += c.reverse
+	}
+
+	return cost
+}
+
+// Cost config files have one setting per line, with blank lines and lines starting with # ignored
+//
+//	step 1
+//	turn 1000
+//	reverse 1000
+//	tile 3,5 10
+//	end any
+//
+// Any setting left out keeps the default cost, and tile can be repeated to weight multiple tiles
+// end is either up or any, for the directions the reindeer can be facing when it reaches the end
+func costModelFromInput(input string) (CostModel, error) {
+	costs := DefaultCostModel()
+
+	for i, line := range strings.Split(input, "\n") {
+		fields := strings.Fields(line)
+
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		var err error
+
+		switch {
+		case fields[0] == "step" && len(fields) == 2:
+			costs.step, err = strconv.Atoi(fields[1])
+		case fields[0] == "turn" && len(fields) == 2:
+			costs.turn, err = strconv.Atoi(fields[1])
+		case fields[0] == "reverse" && len(fields) == 2:
+			costs.reverse, err = strconv.Atoi(fields[1])
+		case fields[0] == "tile" && len(fields) == 3:
+			var pos Point
+			var weight int
+
+			if _, err = fmt.Sscanf(fields[1], "%d,%d", &pos.x, &pos.y); err == nil {
+				weight, err = strconv.Atoi(fields[2])
+				costs.weights[pos] = weight
+			}
+		case fields[0] == "end" && len(fields) == 2 && (fields[1] == "up" || fields[1] == "any"):
+			costs.anyEnd = fields[1] == "any"
+		default:
+			err = fmt.Errorf("unknown setting %q", line)
+		}
+
+		if err != nil {
+			return costs, fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+
+	return costs, nil
+}
+
 func main() {
 	if cap(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [input-file]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [input-file] [cost-file/puzzle]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [input-file] step turn reverse\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s generate width height [loop-density] [seed]\n", os.Args[0])
 		os.Exit(-1)
 	}
 
+	if os.Args[1] == "generate" {
+		generate()
+		return
+	}
+
 	inputContents, err := os.ReadFile(os.Args[1])
 	if err != nil {
 		panic(err)
 	}
 
 	maze := newMazeFromInput(string(inputContents))
+	costs := DefaultCostModel()
+
+	switch len(os.Args) {
+	case 3:
+		if os.Args[2] == "puzzle" {
+			costs = PuzzleCostModel()
+			break
+		}
+
+		costContents, err := os.ReadFile(os.Args[2])
+		if err != nil {
+			panic(err)
+		}
+
+		if costs, err = costModelFromInput(string(costContents)); err != nil {
+			panic(err)
+		}
+	case 5:
+		costs.step = mustAtoi(os.Args[2])
+		costs.turn = mustAtoi(os.Args[3])
+		costs.reverse = mustAtoi(os.Args[4])
+	case 2:
+	default:
+		fmt.Fprintf(os.Stderr, "Usage: %s [input-file] [cost-file/puzzle]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [input-file] step turn reverse\n", os.Args[0])
+		os.Exit(-1)
+	}
+
+	solve(maze, costs)
+}
+
+func mustAtoi(str string) int {
+	n, err := strconv.Atoi(str)
+
+	if err != nil {
+		panic(err)
+	}
 
-	solve(maze)
+	return n
 }
 
 func newMazeFromInput(input string) *Maze {
@@ -184,7 +328,10 @@ func (n Node) String() string {
 	return fmt.Sprintf("%d,%d-%d,%d", n.pos.x, n.pos.y, n.dir.x, n.dir.y)
 }
 
-func solve(maze *Maze) {
+// Graph key of the node the end tile leads into from each direction the reindeer can finish facing
+const endKey = "end"
+
+func solve(maze *Maze, costs CostModel) {
 	// Create graph
 	graph := dijkstra.Graph{}
 
@@ -202,14 +349,11 @@ func solve(maze *Maze) {
 
 						for _, neighbor := range neighbors {
 							neighborNode := Node{neighbor.tile.pos, neighbor.dir}
-							dist := 1
-
-							// Add another 1000 distance if we have to rotate
-							if dir != neighbor.dir {
-								dist += 1000
-							}
+							neighborMap[neighborNode.String()] = costs.MoveCost(dir, neighbor.dir, neighbor.tile.pos)
+						}
 
-							neighborMap[neighborNode.String()] = dist
+						if tile.kind == End && (costs.anyEnd || dir == Up) {
+							neighborMap[endKey] = 0
 						}
 
 						graph[node.String()] = neighborMap
@@ -221,10 +365,175 @@ func solve(maze *Maze) {
 		}
 	}
 
+	graph[endKey] = map[string]int{}
+
 	startNode := Node{maze.startTile.pos, Right}
-	endNode := Node{maze.endTile.pos, Up}
 
-	_, cost, _ := graph.Path(startNode.String(), endNode.String()) // skipping error handling
+	_, cost, _ := graph.Path(startNode.String(), endKey) // skipping error handling
 
 	fmt.Println("Cost:", cost)
 }
+
+// Print a randomly generated maze in the same format as the input
+func generate() {
+	if cap(os.Args) < 4 {
+		fmt.Fprintf(os.Stderr, "Usage: %s generate width height [loop-density] [seed]\n", os.Args[0])
+		os.Exit(-1)
+	}
+
+	width, err := strconv.Atoi(os.Args[2])
+	if err != nil || width < 5 {
+		fmt.Fprintf(os.Stderr, "Invalid width\n")
+		os.Exit(-1)
+	}
+
+	height, err := strconv.Atoi(os.Args[3])
+	if err != nil || height < 5 {
+		fmt.Fprintf(os.Stderr, "Invalid height\n")
+		os.Exit(-1)
+	}
+
+	loopDensity := 0.0
+	if len(os.Args) > 4 {
+		if loopDensity, err = strconv.ParseFloat(os.Args[4], 64); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid loop-density\n")
+			os.Exit(-1)
+		}
+	}
+
+	seed := rand.Uint64()
+	if len(os.Args) > 5 {
+		if seed, err = strconv.ParseUint(os.Args[5], 10, 64); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid seed\n")
+			os.Exit(-1)
+		}
+	}
+
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	// Large mazes are written straight from the carved grid, since building their tile map takes far longer than carving them
+	out := bufio.NewWriter(os.Stdout)
+
+	if _, err := carveMaze(width, height, loopDensity, rng).WriteTo(out); err != nil {
+		panic(err)
+	}
+
+	if err := out.Flush(); err != nil {
+		panic(err)
+	}
+}
+
+// Tiles of a generated maze, indexed by y * width + x
+type mazeGrid struct {
+	width, height int
+	kinds         []TileKind
+}
+
+// Write the grid in the same format as the input
+func (g mazeGrid) WriteTo(w io.Writer) (int64, error) {
+	glyphs := map[TileKind]byte{Wall: '#', Path: '.', Start: 'S', End: 'E'}
+	line := make([]byte, g.width+1)
+	line[g.width] = '\n'
+	written := int64(0)
+
+	for y := 0; y < g.height; y++ {
+		for x, kind := range g.kinds[y*g.width : (y+1)*g.width] {
+			line[x] = glyphs[kind]
+		}
+
+		n, err := w.Write(line)
+		written += int64(n)
+
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// Carve a maze using a recursive backtracker
+// Cells sit on odd coordinates with walls between them, so even dimensions are shrunk by one
+// A backtracked maze has exactly one route between any two cells
+// loopDensity is the fraction of the remaining inner walls between cells to knock down, adding alternate routes
+func carveMaze(width, height int, loopDensity float64, rng *rand.Rand) mazeGrid {
+	width -= 1 - width%2
+	height -= 1 - height%2
+
+	// Open tiles, indexed by y * width + x
+	open := make([]bool, width*height)
+	isOpen := func(p Point) bool { return open[p.y*width+p.x] }
+	setOpen := func(p Point) { open[p.y*width+p.x] = true }
+
+	// Carve passages by walking to random unvisited cells two steps away, backing up when stuck
+	first := Point{1, 1}
+	setOpen(first)
+	stack := []Point{first}
+
+	for len(stack) > 0 {
+		cell := stack[len(stack)-1]
+		unvisited := make([]Vec, 0)
+
+		dir := Up
+		for i := 0; i < 4; i++ {
+			next := cell.Add(dir).Add(dir)
+
+			if next.x > 0 && next.y > 0 && next.x < width-1 && next.y < height-1 && !isOpen(next) {
+				unvisited = append(unvisited, dir)
+			}
+
+			dir = dir.Clockwise()
+		}
+
+		if len(unvisited) == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		dir = unvisited[rng.IntN(len(unvisited))]
+		next := cell.Add(dir).Add(dir)
+
+		setOpen(cell.Add(dir))
+		setOpen(next)
+		stack = append(stack, next)
+	}
+
+	// Knock down walls that separate two cells to create loops
+	for y := 1; y < height-1; y++ {
+		for x := 1; x < width-1; x++ {
+			pos := Point{x, y}
+
+			// Walls between cells have exactly one odd coordinate
+			if isOpen(pos) || x%2 == y%2 {
+				continue
+			}
+
+			if rng.Float64() < loopDensity {
+				setOpen(pos)
+			}
+		}
+	}
+
+	// Like the puzzle, start in the bottom left corner and end in the top right corner
+	// The end is entered from below, since by default the reindeer needs to be facing up when it arrives
+	start := Point{1, height - 2}
+	end := Point{width - 2, 1}
+	setOpen(end.Add(Down))
+
+	grid := mazeGrid{width, height, make([]TileKind, width*height)}
+
+	for i := range grid.kinds {
+		pos := Point{i % width, i / width}
+
+		switch {
+		case pos == start:
+			grid.kinds[i] = Start
+		case pos == end:
+			grid.kinds[i] = End
+		case isOpen(pos):
+			grid.kinds[i] = Path
+		}
+	}
+
+	return grid
+}
@@ -75,60 +75,229 @@ func main() {
 	solve(corruptions, ram, width, height)
 }
 
+// A single step of the simulation, kept in the history so playback can move backwards
+type Frame struct {
+	point Point
+	paths []string
+	cost  int
+}
+
+// Playback delays in milliseconds, ordered from fastest to slowest
+var speeds = []int{0, 10, 25, 50, 100, 250, 500, 1000}
+
+// Characters used to draw the path length trend, from shortest to longest
+const sparks = "_.-=+*#"
+
 // Drops each byte one by one from input into ram and finds the best path from start to end
 // Each iteration is displayed showing the found path
-// Stops once it reaches a byte that blocks any access to the end point
+// Playback can be paused, stepped in either direction, sped up or slowed down, or jumped to a specific byte
+// Automatically pauses once it reaches a byte that blocks any access to the end point
 func solve(input Input, ram Ram, width, height int) {
 	stdscr, _ := goncurses.Init()
 	defer goncurses.End()
 
-	// Part 1 requires finding the cost of the 1024th byte
-	kbCost := "Pending"
+	goncurses.CBreak(true)
+	goncurses.Echo(false)
+	goncurses.Cursor(0)
+	stdscr.Keypad(true)
+
+	// Frames are computed the first time they're displayed and kept for stepping backwards
+	history := make([]*Frame, len(input))
+	current := 0
+	speed := 3
+	paused := false
+
+	for len(input) > 0 {
+		// Part 1 needs the 1024th byte, so make sure it's computed even when a jump skips over it
+		if current > 1023 && history[1023] == nil {
+			frameAt(history, input, ram, 1023, width, height)
+		}
+
+		frame := frameAt(history, input, ram, current, width, height)
+
+		display(stdscr, history, input, ram, current, width, height)
 
-	for i, n := range input {
-		stdscr.Clear()
+		status := "Playing"
+		if paused {
+			status = "Paused"
+		}
+
+		stdscr.Println(status, " Delay:", strconv.Itoa(speeds[speed])+"ms")
+		stdscr.Println("[space] pause  [left/right] step  [+/-] speed  [g] jump to byte  [q] quit")
+		stdscr.Refresh()
+
+		if paused {
+			stdscr.Timeout(-1)
+		} else {
+			stdscr.Timeout(speeds[speed])
+		}
+
+		switch key := stdscr.GetChar(); key {
+		case 'q':
+			return
+		case ' ':
+			paused = !paused
+		case goncurses.KEY_RIGHT, 'n':
+			paused = true
+			current = min(current+1, len(input)-1)
+		case goncurses.KEY_LEFT, 'p':
+			paused = true
+			current = max(current-1, 0)
+		case '+', '=':
+			speed = max(speed-1, 0)
+		case '-':
+			speed = min(speed+1, len(speeds)-1)
+		case 'g':
+			if n, ok := promptByte(stdscr, len(input)); ok {
+				current = n - 1
+				paused = true
+			}
+		case 0:
+			// Timed out waiting for a key, so advance to the next byte while playing
+			if paused {
+				break
+			}
 
+			// Pause when the path first becomes blocked or there are no bytes left to drop
+			// Bytes after the blocking one are blocked too, so playback can carry on past it once resumed
+			if (frame.cost == 0 && !blockedBefore(history, current)) || current == len(input)-1 {
+				paused = true
+			} else {
+				current++
+			}
+		}
+	}
+}
+
+// Returns whether the path was already blocked at the byte before the given index
+// A byte skipped over by a jump hasn't been computed, so it isn't known to be blocked
+func blockedBefore(history []*Frame, index int) bool {
+	return index > 0 && history[index-1] != nil && history[index-1].cost == 0
+}
+
+// Fetch the frame for the byte at the given index, computing it if it isn't in the history yet
+// Leaves ram holding every byte up to and including the index
+func frameAt(history []*Frame, input Input, ram Ram, index, width, height int) *Frame {
+	clear(ram)
+
+	for _, n := range input[:index+1] {
 		ram[n] = true
+	}
 
+	if history[index] == nil {
 		paths, cost, _ := pathfind(ram, width, height)
+		history[index] = &Frame{input[index], paths, cost}
+	}
 
-		if i == 1023 {
-			kbCost = strconv.Itoa(cost)
-		}
+	return history[index]
+}
 
-		stdscr.Println("Byte:", i+1, " Point:", n, " Cost:", cost)
-		stdscr.Println("1024 Cost:", kbCost)
-		stdscr.Println(strings.Repeat("-", width))
+// Draw the frame at the given index along with its status lines
+func display(stdscr *goncurses.Window, history []*Frame, input Input, ram Ram, index, width, height int) {
+	frame := history[index]
 
-		// Display map
-		for y := 0; y < height; y++ {
-			for x := 0; x < width; x++ {
-				pt := Point{x, y}
+	// Part 1 requires finding the cost of the 1024th byte
+	kbCost := "Pending"
 
-				// Points within the found path to the end are displayed as an O
-				// Corrupted spaces a #
-				// Walkable spaces a .
-				if slices.Index(paths, pt.String()) > -1 {
-					stdscr.Print("O")
-				} else {
+	if len(history) > 1023 && history[1023] != nil {
+		kbCost = strconv.Itoa(history[1023].cost)
+	}
 
-					if ram[pt] {
-						stdscr.Print("#")
-					} else {
-						stdscr.Print(".")
-					}
+	stdscr.Clear()
+
+	stdscr.Println("Byte:", index+1, "/", len(input), " Point:", frame.point, " Cost:", frame.cost)
+	stdscr.Println("1024 Cost:", kbCost)
+	stdscr.Println(trend(history, index, width))
+	stdscr.Println(strings.Repeat("-", width))
+
+	// Display map
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			pt := Point{x, y}
+
+			// Points within the found path to the end are displayed as an O
+			// Corrupted spaces a #
+			// Walkable spaces a .
+			if slices.Index(frame.paths, pt.String()) > -1 {
+				stdscr.Print("O")
+			} else {
+
+				if ram[pt] {
+					stdscr.Print("#")
+				} else {
+					stdscr.Print(".")
 				}
 			}
+		}
+
+		stdscr.Print("\n")
+	}
+
+	stdscr.Println(strings.Repeat("-", width))
+}
+
+// Build a status line showing how the path length has changed over the most recently computed frames
+// Frames that were skipped over by a jump are left out
+func trend(history []*Frame, index, width int) string {
+	costs := make([]int, 0)
 
-			stdscr.Print("\n")
+	for i := index; i >= 0 && len(costs) < width; i-- {
+		if history[i] != nil {
+			costs = append(costs, history[i].cost)
 		}
+	}
 
-		stdscr.Refresh()
+	slices.Reverse(costs)
 
-		if cost == 0 {
-			stdscr.GetChar()
+	change := "="
+	if len(costs) > 1 {
+		delta := costs[len(costs)-1] - costs[len(costs)-2]
+
+		if delta > 0 {
+			change = "+" + strconv.Itoa(delta)
+		} else if delta < 0 {
+			change = strconv.Itoa(delta)
+		}
+	}
+
+	// Scale each cost between the lowest and highest cost seen to pick its spark
+	low, high := slices.Min(costs), slices.Max(costs)
+	line := make([]byte, len(costs))
+
+	for i, cost := range costs {
+		level := 0
+		if high > low {
+			level = (cost - low) * (len(sparks) - 1) / (high - low)
 		}
+
+		line[i] = sparks[level]
+	}
+
+	return fmt.Sprintf("Trend: %s %s", change, line)
+}
+
+// Ask for a byte number to jump to
+// Returns false if the entry isn't a number between 1 and the count of bytes
+func promptByte(stdscr *goncurses.Window, count int) (int, bool) {
+	goncurses.Echo(true)
+	goncurses.Cursor(1)
+	defer goncurses.Echo(false)
+	defer goncurses.Cursor(0)
+
+	stdscr.Timeout(-1)
+	stdscr.Printf("Jump to byte (1-%d): ", count)
+
+	entry, err := stdscr.GetString(10)
+	if err != nil {
+		return 0, false
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(entry))
+	if err != nil || n < 1 || n > count {
+		return 0, false
 	}
+
+	return n, true
 }
 
 // Convert input file of points to a slice
@@ -4,9 +4,19 @@
 package main
 
 import (
+	"cmp"
+	"encoding/csv"
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"maps"
 	"os"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/albertorestifo/dijkstra"
 )
@@ -29,6 +39,13 @@ type (
 		start  Point
 		end    Point
 	}
+
+	// A shortcut through the walls, entering the track at one point and leaving at another
+	Cheat struct {
+		entry   Point
+		exit    Point
+		savings int
+	}
 )
 
 const (
@@ -57,9 +74,122 @@ func (v Vec) Clockwise() Vec {
 	return Vec{-v.y, v.x}
 }
 
+// Return a map of the maze in the same format as the input
+func (m Maze) String() string {
+	var str string
+
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			switch m.tiles[Point{x, y}] {
+			case Wall:
+				str += "#"
+			case Space:
+				str += "."
+			case Start:
+				str += "S"
+			case End:
+				str += "E"
+			}
+		}
+
+		str += "\n"
+	}
+
+	return str
+}
+
+// Size in pixels of each tile when drawn as an image
+const tileSize = 8
+
+// Create an image of the maze
+// Walls are dark grey, and the track is shaded from blue at the start to red at the end by its distance from the start
+// Each cheat is drawn as a yellow line from its entry to its exit, with a white dot at the entry
+func (m Maze) ToImage(fromStart map[Point]int, cheats []Cheat) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, m.width*tileSize, m.height*tileSize))
+	baseCost := max(fromStart[m.end], 1)
+
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			point := Point{x, y}
+			var c color.Color = color.RGBA{48, 48, 48, 255}
+
+			if m.tiles[point] != Wall {
+				// Track that can't be reached from the start is left light grey
+				c = color.RGBA{160, 160, 160, 255}
+
+				if cost, ok := fromStart[point]; ok {
+					shade := uint8(cost * 255 / baseCost)
+					c = color.RGBA{shade, 64, 255 - shade, 255}
+				}
+			}
+
+			fillTile(img, point, c)
+		}
+	}
+
+	for _, cheat := range cheats {
+		drawLine(img, tileCenter(cheat.entry), tileCenter(cheat.exit), color.RGBA{255, 220, 0, 255})
+
+		entry := tileCenter(cheat.entry)
+		img.Set(entry.X, entry.Y, color.White)
+	}
+
+	return img
+}
+
+func fillTile(img *image.RGBA, point Point, c color.Color) {
+	for y := 0; y < tileSize; y++ {
+		for x := 0; x < tileSize; x++ {
+			img.Set(point.x*tileSize+x, point.y*tileSize+y, c)
+		}
+	}
+}
+
+func tileCenter(point Point) image.Point {
+	return image.Point{point.x*tileSize + tileSize/2, point.y*tileSize + tileSize/2}
+}
+
+// Draw a straight line between two pixels using Bresenham's algorithm
+func drawLine(img *image.RGBA, from, to image.Point, c color.Color) {
+	dx := abs(to.X - from.X)
+	dy := -abs(to.Y - from.Y)
+	sx, sy := 1, 1
+
+	if from.X > to.X {
+		sx = -1
+	}
+
+	if from.Y > to.Y {
+		sy = -1
+	}
+
+	err := dx + dy
+	x, y := from.X, from.Y
+
+	for {
+		img.Set(x, y, c)
+
+		if x == to.X && y == to.Y {
+			return
+		}
+
+		e2 := 2 * err
+
+		if e2 >= dy {
+			err += dy
+			x += sx
+		}
+
+		if e2 <= dx {
+			err += dx
+			y += sy
+		}
+	}
+}
+
 func main() {
 	if cap(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [input-file]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [input-file] [min-savings] [max-cheat-length] [report/render/brute] [output-file] [top-cheats]\n", os.Args[0])
 		os.Exit(-1)
 	}
 
@@ -68,41 +198,316 @@ func main() {
 		panic(err)
 	}
 
+	// The puzzle counts cheats saving at least 100 picoseconds
+	// The examples use much smaller amounts
+	minSavings := 100
+	if len(os.Args) > 2 {
+		if minSavings, err = strconv.Atoi(os.Args[2]); err != nil {
+			panic(err)
+		}
+	}
+
+	// Without a cheat length both parts are solved
+	maxLength := 0
+	if len(os.Args) > 3 {
+		if maxLength, err = strconv.Atoi(os.Args[3]); err != nil {
+			panic(err)
+		}
+	}
+
 	maze := newMazeFromInput(string(inputContents))
 
-	solve(maze)
+	mode := ""
+	if len(os.Args) > 4 {
+		mode = os.Args[4]
+	}
+
+	switch mode {
+	case "report":
+		report(maze, minSavings, maxLength)
+	case "render":
+		if len(os.Args) < 6 {
+			fmt.Fprintf(os.Stderr, "Missing output-file\n")
+			os.Exit(-1)
+		}
+
+		// Only the biggest cheats are drawn so the track stays readable
+		topCheats := 10
+		if len(os.Args) > 6 {
+			if topCheats, err = strconv.Atoi(os.Args[6]); err != nil {
+				panic(err)
+			}
+		}
+
+		render(maze, minSavings, maxLength, topCheats, os.Args[5])
+	case "brute":
+		verify(maze, minSavings)
+	default:
+		solve(maze, minSavings, maxLength)
+	}
+}
+
+func solve(maze Maze, minSavings, maxLength int) {
+	fromStart := distances(maze, maze.start)
+	toEnd := distances(maze, maze.end)
+
+	fmt.Println("Base Cost:", fromStart[maze.end])
+
+	if maxLength == 0 {
+		// Part 1 cheats can pass through a single wall, taking 2 picoseconds
+		// Part 2 cheats can last up to 20 picoseconds
+		fmt.Println("Part 1:", countCheats(cheatSavings(maze, fromStart, toEnd, 2, minSavings)))
+		fmt.Println("Part 2:", countCheats(cheatSavings(maze, fromStart, toEnd, 20, minSavings)))
+
+		return
+	}
+
+	// Print the histogram the same way the puzzle describes the examples
+	cheatableSavings := cheatSavings(maze, fromStart, toEnd, maxLength, minSavings)
+	savings := slices.Sorted(maps.Keys(cheatableSavings))
+
+	for _, saving := range savings {
+		fmt.Printf("There are %d cheats that save %d picoseconds.\n", cheatableSavings[saving], saving)
+	}
+
+	fmt.Println("Cheats:", countCheats(cheatableSavings))
 }
 
-func solve(maze Maze) {
-	baseCost := dijkstraCost(maze)
+// Write every cheat as CSV, ordered by the amount of picoseconds saved
+func report(maze Maze, minSavings, maxLength int) {
+	cheats := sortedCheats(maze, minSavings, maxLength)
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"savings", "start_x", "start_y", "end_x", "end_y"})
+
+	for _, cheat := range cheats {
+		w.Write([]string{
+			strconv.Itoa(cheat.savings),
+			strconv.Itoa(cheat.entry.x),
+			strconv.Itoa(cheat.entry.y),
+			strconv.Itoa(cheat.exit.x),
+			strconv.Itoa(cheat.exit.y),
+		})
+	}
 
-	fmt.Println("Base Cost:", baseCost)
+	w.Flush()
 
+	if err := w.Error(); err != nil {
+		panic(err)
+	}
+}
+
+// Print the track and write an image of it with the top cheats drawn over it
+func render(maze Maze, minSavings, maxLength, topCheats int, outputFile string) {
+	cheats := sortedCheats(maze, minSavings, maxLength)
+
+	// Cheats are sorted from least to most savings, so the top cheats are at the end
+	top := cheats[max(len(cheats)-topCheats, 0):]
+	slices.Reverse(top)
+
+	fmt.Print(maze.String())
+
+	for _, cheat := range top {
+		fmt.Printf("%d: %s -> %s\n", cheat.savings, cheat.entry, cheat.exit)
+	}
+
+	f, err := os.Create(outputFile)
+	if err != nil {
+		panic(err)
+	}
+
+	defer f.Close()
+
+	if err := png.Encode(f, maze.ToImage(distances(maze, maze.start), top)); err != nil {
+		panic(err)
+	}
+}
+
+// Find every cheat saving at least minSavings, ordered by the amount of picoseconds saved
+// Cheats saving the same amount are ordered by their entry then exit points
+func sortedCheats(maze Maze, minSavings, maxLength int) []Cheat {
+	// Default to the part 1 cheat length
+	if maxLength == 0 {
+		maxLength = 2
+	}
+
+	fromStart := distances(maze, maze.start)
+	toEnd := distances(maze, maze.end)
+	cheats := make([]Cheat, 0)
+
+	eachCheat(maze, fromStart, toEnd, maxLength, minSavings, func(cheat Cheat) {
+		cheats = append(cheats, cheat)
+	})
+
+	slices.SortFunc(cheats, func(a, b Cheat) int {
+		return cmp.Or(
+			cmp.Compare(a.savings, b.savings),
+			cmp.Compare(a.entry.y, b.entry.y),
+			cmp.Compare(a.entry.x, b.entry.x),
+			cmp.Compare(a.exit.y, b.exit.y),
+			cmp.Compare(a.exit.x, b.exit.x),
+		)
+	})
+
+	return cheats
+}
+
+// Compare the part 1 answer from the distance fields against the brute force reference
+func verify(maze Maze, minSavings int) {
+	fromStart := distances(maze, maze.start)
+	toEnd := distances(maze, maze.end)
+
+	expected := countCheats(cheatSavings(maze, fromStart, toEnd, 2, minSavings))
+	actual := 0
+
+	for savings, count := range bruteForceSavings(maze) {
+		if savings > 0 && savings >= minSavings {
+			actual += count
+		}
+	}
+
+	fmt.Println("Distance Fields:", expected)
+	fmt.Println("Brute Force:", actual)
+
+	if expected != actual {
+		fmt.Println("Mismatch")
+		os.Exit(1)
+	}
+}
+
+// Reference implementation of part 1
+// Opens each cheatable wall in turn and finds the new cost of the whole maze
+// Walls are evaluated in parallel, with one worker per CPU
+// Returns how many cheats save each amount of picoseconds
+func bruteForceSavings(maze Maze) map[int]int {
+	baseCost := dijkstraCost(maze, nil)
+
+	// Evaluate walls in reading order so results are always merged the same way
 	cheatables := cheatableWalls(maze)
+	slices.SortFunc(cheatables, func(a, b Point) int {
+		return cmp.Or(cmp.Compare(a.y, b.y), cmp.Compare(a.x, b.x))
+	})
+
+	// Each worker only writes the savings of the walls it was handed
+	savings := make([]int, len(cheatables))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				// The maze is shared between workers, so the opened wall is passed alongside it instead of changing its tiles
+				opened := map[Point]bool{cheatables[i]: true}
+				savings[i] = baseCost - dijkstraCost(maze, opened)
+			}
+		}()
+	}
+
+	for i := range cheatables {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	cheatableSavings := make(map[int]int)
+
+	for _, saving := range savings {
+		cheatableSavings[saving]++
+	}
+
+	return cheatableSavings
+}
+
+// Find how many cheats save each amount of picoseconds
+// Only cheats saving at least minSavings are included
+func cheatSavings(maze Maze, fromStart, toEnd map[Point]int, maxLength, minSavings int) map[int]int {
 	cheatableSavings := make(map[int]int)
 
-	for _, cheatable := range cheatables {
-		maze.tiles[cheatable] = Space
-		cost := dijkstraCost(maze)
+	eachCheat(maze, fromStart, toEnd, maxLength, minSavings, func(cheat Cheat) {
+		cheatableSavings[cheat.savings]++
+	})
+
+	return cheatableSavings
+}
+
+// Call fn with every cheat saving at least minSavings
+// A cheat enters at any track point and exits at any track point within maxLength steps, ignoring walls
+func eachCheat(maze Maze, fromStart, toEnd map[Point]int, maxLength, minSavings int, fn func(Cheat)) {
+	baseCost := fromStart[maze.end]
+
+	for entry, entryCost := range fromStart {
+		for dy := -maxLength; dy <= maxLength; dy++ {
+			reach := maxLength - abs(dy)
+
+			for dx := -reach; dx <= reach; dx++ {
+				exit := Point{entry.x + dx, entry.y + dy}
+
+				exitCost, ok := toEnd[exit]
+				if !ok {
+					continue
+				}
+
+				savings := baseCost - (entryCost + abs(dx) + abs(dy) + exitCost)
 
-		cheatableSavings[baseCost-cost]++
+				if savings > 0 && savings >= minSavings {
+					fn(Cheat{entry, exit, savings})
+				}
+			}
+		}
+	}
+}
+
+// Total the number of cheats within a savings histogram
+func countCheats(cheatableSavings map[int]int) int {
+	total := 0
 
-		// Set the cheatable wall back to a plain wall for the next iteration
-		maze.tiles[cheatable] = Wall
+	for _, count := range cheatableSavings {
+		total += count
 	}
 
-	part1 := 0
+	return total
+}
+
+// Walk outwards from a point to find the number of steps to every reachable track point
+func distances(maze Maze, from Point) map[Point]int {
+	costs := map[Point]int{from: 0}
+	queue := []Point{from}
 
-	for savings, count := range cheatableSavings {
-		if savings >= 100 {
-			part1 += count
+	for len(queue) > 0 {
+		point := queue[0]
+		queue = queue[1:]
+
+		for _, neighbor := range neighbors(maze, point) {
+			if maze.tiles[neighbor] == Wall {
+				continue
+			}
+
+			if _, seen := costs[neighbor]; !seen {
+				costs[neighbor] = costs[point] + 1
+				queue = append(queue, neighbor)
+			}
 		}
 	}
 
-	fmt.Println("Part 1:", part1)
+	return costs
 }
 
-func dijkstraCost(maze Maze) int {
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
+
+// Find the cost of the shortest path from start to end
+// Any walls in opened are treated as track
+func dijkstraCost(maze Maze, opened map[Point]bool) int {
 	graph := dijkstra.Graph{}
 
 	for y := 0; y < maze.height; y++ {
@@ -110,7 +515,7 @@ func dijkstraCost(maze Maze) int {
 			point := Point{x, y}
 
 			if tile, ok := maze.tiles[point]; ok {
-				if tile != Wall {
+				if tile != Wall || opened[point] {
 					neighbors := neighbors(maze, point)
 					neighborMap := make(map[string]int)
 
@@ -191,7 +596,7 @@ func cheatableWalls(maze Maze) []Point {
 }
 
 func newMazeFromInput(input string) Maze {
-	lines := strings.Split(input, "\n")
+	lines := strings.Split(strings.TrimSpace(input), "\n")
 	height := len(lines)
 	width := len(lines[0])
 	tiles := make(map[Point]Tile)
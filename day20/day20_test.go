@@ -0,0 +1,49 @@
+package main
+
+import (
+	"maps"
+	"testing"
+)
+
+// Example racetrack from the puzzle
+const example = `###############
+#...#...#.....#
+#.#.#.#.#.###.#
+#S#...#.#.#...#
+#######.#.#.###
+#######.#.#...#
+#######.#.###.#
+###..E#...#...#
+###.#######.###
+#...###...#...#
+#.#####.#.###.#
+#.#...#.#.#...#
+#.#.#.#.#.#.###
+#...#...#...###
+###############`
+
+// The brute force savings are computed across a worker pool, so run this with go test -race to check the workers don't share state
+func TestBruteForceSavingsMatchesDistanceFields(t *testing.T) {
+	maze := newMazeFromInput(example)
+	fromStart := distances(maze, maze.start)
+	toEnd := distances(maze, maze.end)
+
+	expected := cheatSavings(maze, fromStart, toEnd, 2, 1)
+
+	// Walls that don't shorten the race are left out, the same as cheatSavings does
+	actual := make(map[int]int)
+
+	for savings, count := range bruteForceSavings(maze) {
+		if savings > 0 {
+			actual[savings] = count
+		}
+	}
+
+	if !maps.Equal(expected, actual) {
+		t.Errorf("brute force savings %v, distance field savings %v", actual, expected)
+	}
+
+	if count := countCheats(expected); count != 44 {
+		t.Errorf("expected 44 cheats, got %d", count)
+	}
+}
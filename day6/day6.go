@@ -4,7 +4,14 @@ package main
 
 import (
 	"fmt"
+	"image"
+	"image/color"
+	"image/png"
 	"os"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
 )
 
 type Point struct {
@@ -23,6 +30,42 @@ func (v Vec) Rotate() Vec {
 	return Vec{x: -v.y, y: v.x}
 }
 
+// Direction a guard is facing from its character on the map
+func directionFromGlyph(b byte) (Vec, bool) {
+	switch b {
+	case '^':
+		return Vec{0, -1}, true
+
+	case '>':
+		return Vec{1, 0}, true
+
+	case 'v':
+		return Vec{0, 1}, true
+
+	case '<':
+		return Vec{-1, 0}, true
+	}
+
+	return Vec{}, false
+}
+
+// Character used to draw a guard facing this direction
+func (v Vec) Glyph() string {
+	switch v {
+	case Vec{0, -1}:
+		return "^"
+
+	case Vec{1, 0}:
+		return ">"
+
+	case Vec{0, 1}:
+		return "v"
+
+	default:
+		return "<"
+	}
+}
+
 type Visit struct {
 	point     Point
 	direction Vec
@@ -33,6 +76,7 @@ type Map struct {
 	width, height     int
 	startingPoint     Point
 	startingDirection Vec
+	guards            []Guard
 }
 
 func newMap() Map {
@@ -70,6 +114,16 @@ func (m *Map) SetStartingPoint(p Point, v Vec) {
 	m.startingDirection = v
 }
 
+// Add a guard found on the map
+// The first guard added is where the single guard starts
+func (m *Map) AddGuard(p Point, v Vec) {
+	if len(m.guards) == 0 {
+		m.SetStartingPoint(p, v)
+	}
+
+	m.guards = append(m.guards, Guard{p, v})
+}
+
 func (m *Map) SetDimensions(width, height int) {
 	m.width = width
 	m.height = height
@@ -86,18 +140,52 @@ func newGuard(m *Map) Guard {
 
 func main() {
 	if cap(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [inputfile]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [inputfile] [fast/brute/compare/draw/multi] [output-png]\n", os.Args[0])
 		os.Exit(-1)
 	}
 
 	m := newMap()
 
-	readInputIntoMap(&m, os.Args[1])
+	if err := readInputIntoMap(&m, os.Args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(-1)
+	}
+
+	mode := "fast"
+	if len(os.Args) > 2 {
+		mode = os.Args[2]
+	}
+
+	// Multiple guards are only simulated when asked for
+	if mode == "multi" {
+		multiGuards(&m)
+		return
+	}
+
+	if len(m.guards) > 1 {
+		fmt.Fprintf(os.Stderr, "Found %d guards, expected 1\n", len(m.guards))
+		os.Exit(-1)
+	}
 
 	guard := newGuard(&m)
 
 	part1(&guard, &m)
-	part2(&guard, &m)
+
+	switch mode {
+	case "brute":
+		part2(&m)
+	case "compare":
+		compareLoops(&m)
+	case "draw":
+		if len(os.Args) < 4 {
+			fmt.Fprintf(os.Stderr, "Missing output-png\n")
+			os.Exit(-1)
+		}
+
+		drawLoops(&m, os.Args[3])
+	default:
+		fmt.Println("Part 2:", len(fastLoopObstacles(&m)))
+	}
 }
 
 // Part 1 finds all the distinct places on the map the guard visited
@@ -106,9 +194,9 @@ func part1(guard *Guard, m *Map) {
 
 	// Loop guard movement until the guard attempts to leave the map
 	for {
-		next, _ := nextVisit(guard, m)
+		next, ok := nextVisit(guard, m, noObstruction)
 
-		if !m.InBounds(next.point) {
+		if !ok || !m.InBounds(next.point) {
 			break
 		}
 
@@ -123,8 +211,16 @@ func part1(guard *Guard, m *Map) {
 }
 
 // Part 2 finds all spots where an additional obstruction could be placed to cause the guard to go in an infinite loop
-func part2(guard *Guard, m *Map) {
-	numInfiniteLoops := 0
+// Each spot is simulated independently, so they're spread across one worker per CPU
+func part2(m *Map) {
+	fmt.Println("Part 2:", len(bruteLoopObstacles(m)))
+}
+
+// Reference implementation of part 2
+// Tries an obstruction at every open point, walking the guard in parallel with one worker per CPU
+// Returns the obstructions that trap the guard in a loop, in reading order
+func bruteLoopObstacles(m *Map) []Point {
+	candidates := make([]Point, 0)
 
 	for y := 0; y < m.height; y++ {
 		for x := 0; x < m.width; x++ {
@@ -140,73 +236,329 @@ func part2(guard *Guard, m *Map) {
 				continue
 			}
 
-			// Place an obstable in the test spot
-			m.PlaceObstable(p)
+			candidates = append(candidates, p)
+		}
+	}
 
-			visits := make(map[Visit]bool)
+	// Each worker only writes the results of the candidates it was handed
+	loops := make([]bool, len(candidates))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
 
-			// Reset guard position
-			guard.point = m.startingPoint
-			guard.direction = m.startingDirection
+	for w := 0; w < runtime.GOMAXPROCS(0); w++ {
+		wg.Add(1)
 
-			for {
-				next, ok := nextVisit(guard, m)
+		go func() {
+			defer wg.Done()
 
-				if !ok {
-					break
-				}
+			for i := range jobs {
+				loops[i] = causesLoop(m, candidates[i])
+			}
+		}()
+	}
 
-				// If guard already visited this space in this direction, then we've hit an infinite loop
-				if _, ok := visits[next]; ok {
-					numInfiniteLoops++
-					break
-				}
+	for i := range candidates {
+		jobs <- i
+	}
 
-				// If the guard left the map, then we're also done
-				if !m.InBounds(next.point) {
-					break
-				}
+	close(jobs)
+	wg.Wait()
+
+	obstacles := make([]Point, 0)
+
+	for i, loop := range loops {
+		if loop {
+			obstacles = append(obstacles, candidates[i])
+		}
+	}
+
+	return obstacles
+}
+
+// Walk the guard around the map with an obstruction placed at the given point
+// Returns true if the guard ends up in an infinite loop
+// The map itself is left untouched, so multiple obstructions can be tested at once
+func causesLoop(m *Map, obstruction Point) bool {
+	guard := newGuard(m)
+	visits := make(map[Visit]bool)
+
+	for {
+		next, ok := nextVisit(&guard, m, obstruction)
+
+		// A guard boxed in by the obstruction turns in place forever
+		if !ok {
+			return true
+		}
+
+		// If guard already visited this space in this direction, then we've hit an infinite loop
+		if _, ok := visits[next]; ok {
+			return true
+		}
+
+		// If the guard left the map, then we're also done
+		if !m.InBounds(next.point) {
+			return false
+		}
+
+		// Add visit to list and move guard
+		visits[Visit{next.point, next.direction}] = true
+
+		guard.point = next.point
+		guard.direction = next.direction
+	}
+}
+
+// Index of a direction for storing visited states
+func directionIndex(v Vec) int {
+	switch v {
+	case Vec{0, -1}:
+		return 0
+	case Vec{1, 0}:
+		return 1
+	case Vec{0, 1}:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Obstacle positions sorted along each row and column, so the guard can jump straight to the next one
+type JumpTable struct {
+	rows map[int][]int // x positions of the obstacles in each row
+	cols map[int][]int // y positions of the obstacles in each column
+}
+
+func newJumpTable(m *Map) JumpTable {
+	j := JumpTable{rows: make(map[int][]int), cols: make(map[int][]int)}
+
+	for p := range m.obstacles {
+		j.rows[p.y] = append(j.rows[p.y], p.x)
+		j.cols[p.x] = append(j.cols[p.x], p.y)
+	}
+
+	for _, xs := range j.rows {
+		sort.Ints(xs)
+	}
+
+	for _, ys := range j.cols {
+		sort.Ints(ys)
+	}
+
+	return j
+}
+
+// Find where the guard stops when walking from p in direction dir, treating extra as another obstacle
+// The guard stops on the point right before the obstacle it walks into
+// Returns false if there's no obstacle and the guard walks off the map
+func (j JumpTable) Jump(p Point, dir Vec, extra Point) (Point, bool) {
+	switch dir {
+	case Vec{0, -1}:
+		ys := j.cols[p.x]
+		stop, found := -1, false
+
+		if i := sort.SearchInts(ys, p.y); i > 0 {
+			stop, found = ys[i-1], true
+		}
+
+		if extra.x == p.x && extra.y < p.y && extra.y > stop {
+			stop, found = extra.y, true
+		}
+
+		return Point{p.x, stop + 1}, found
+
+	case Vec{0, 1}:
+		ys := j.cols[p.x]
+		stop, found := 0, false
+
+		if i := sort.SearchInts(ys, p.y+1); i < len(ys) {
+			stop, found = ys[i], true
+		}
+
+		if extra.x == p.x && extra.y > p.y && (!found || extra.y < stop) {
+			stop, found = extra.y, true
+		}
+
+		return Point{p.x, stop - 1}, found
+
+	case Vec{-1, 0}:
+		xs := j.rows[p.y]
+		stop, found := -1, false
+
+		if i := sort.SearchInts(xs, p.x); i > 0 {
+			stop, found = xs[i-1], true
+		}
+
+		if extra.y == p.y && extra.x < p.x && extra.x > stop {
+			stop, found = extra.x, true
+		}
+
+		return Point{stop + 1, p.y}, found
 
-				// Add visit to list and move guard
-				visits[Visit{next.point, next.direction}] = true
+	default:
+		xs := j.rows[p.y]
+		stop, found := 0, false
 
-				guard.point = next.point
-				guard.direction = next.direction
+		if i := sort.SearchInts(xs, p.x+1); i < len(xs) {
+			stop, found = xs[i], true
+		}
+
+		if extra.y == p.y && extra.x > p.x && (!found || extra.x < stop) {
+			stop, found = extra.x, true
+		}
+
+		return Point{stop - 1, p.y}, found
+	}
+}
+
+// Bitset of visited states, one bit per point and direction
+type VisitSet []uint64
+
+func newVisitSet(m *Map) VisitSet {
+	return make(VisitSet, (m.width*m.height*4+63)/64)
+}
+
+// Mark a state as visited, returning true if it was already visited
+func (v VisitSet) Visit(m *Map, p Point, dir Vec) bool {
+	i := (p.y*m.width+p.x)*4 + directionIndex(dir)
+	mask := uint64(1) << (i % 64)
+	seen := v[i/64]&mask != 0
+
+	v[i/64] |= mask
+
+	return seen
+}
+
+// Find all the distinct points the guard walks through before leaving the map, excluding the starting point
+func guardPath(m *Map) []Point {
+	guard := newGuard(m)
+	seen := make(map[Point]bool)
+	path := make([]Point, 0)
+
+	for {
+		next, ok := nextVisit(&guard, m, noObstruction)
+
+		if !ok || !m.InBounds(next.point) {
+			return path
+		}
+
+		if !seen[next.point] && next.point != m.startingPoint {
+			seen[next.point] = true
+			path = append(path, next.point)
+		}
+
+		guard.point = next.point
+		guard.direction = next.direction
+	}
+}
+
+// Optimized version of part 2
+// An obstruction can only change the guard's route if it's placed somewhere on the original path
+// The guard jumps from obstacle to obstacle, and only the states where it turns are recorded
+func fastLoopObstacles(m *Map) []Point {
+	jumps := newJumpTable(m)
+	visits := newVisitSet(m)
+	loops := make([]Point, 0)
+
+	for _, obstacle := range guardPath(m) {
+		clear(visits)
+
+		point, dir := m.startingPoint, m.startingDirection
+
+		for {
+			stop, ok := jumps.Jump(point, dir, obstacle)
+
+			// Walked off the map
+			if !ok {
+				break
 			}
 
-			// Remove the obstacle
-			m.RemoveObstacle(p)
+			// Turning at the same point in the same direction means the guard is walking in a loop
+			if visits.Visit(m, stop, dir) {
+				loops = append(loops, obstacle)
+				break
+			}
+
+			point, dir = stop, dir.Rotate()
+		}
+	}
+
+	return loops
+}
+
+// Run both versions of part 2 and exit with an error if they find different obstructions
+func compareLoops(m *Map) {
+	fast := make(map[Point]bool)
+	brute := make(map[Point]bool)
+
+	for _, p := range fastLoopObstacles(m) {
+		fast[p] = true
+	}
+
+	for _, p := range bruteLoopObstacles(m) {
+		brute[p] = true
+	}
+
+	fmt.Println("Jump Table:", len(fast))
+	fmt.Println("Brute Force:", len(brute))
+
+	mismatch := false
+
+	for p := range brute {
+		if !fast[p] {
+			fmt.Printf("Missed by jump table: %d,%d\n", p.x, p.y)
+			mismatch = true
+		}
+	}
+
+	for p := range fast {
+		if !brute[p] {
+			fmt.Printf("Missed by brute force: %d,%d\n", p.x, p.y)
+			mismatch = true
 		}
 	}
 
-	fmt.Println("Part 2:", numInfiniteLoops)
+	if mismatch {
+		fmt.Println("Mismatch")
+		os.Exit(1)
+	}
 }
 
+// Point that's never on the map, for when there's no obstruction to place
+var noObstruction = Point{-1, -1}
+
 // Determine how the guard will move next
-func nextVisit(g *Guard, m *Map) (Visit, bool) {
+// The obstruction is treated the same as any obstacle on the map
+// Returns false if the guard is blocked on all four sides
+func nextVisit(g *Guard, m *Map, obstruction Point) (Visit, bool) {
 	dir := g.direction
 
-	for {
+	for i := 0; i < 4; i++ {
 		point := g.point.Add(dir)
 
-		if !m.HasObstacle(point) {
+		if !m.HasObstacle(point) && point != obstruction {
 			return Visit{point, dir}, true
 		}
 
 		// Obstable was in the way, rotate 90 degrees
 		dir = dir.Rotate()
 	}
+
+	// Blocked on all four sides, so the guard can only turn in place
+	return Visit{}, false
 }
 
-func readInputIntoMap(m *Map, filename string) {
+// Read the map from the input file
+// Guards can start facing any direction, and the map must contain at least one guard
+func readInputIntoMap(m *Map, filename string) error {
 	contentBytes, err := os.ReadFile(filename)
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
 	y := 0
 	x := 0
+	width := 0
 
 	for _, b := range contentBytes {
 		switch b {
@@ -218,20 +570,28 @@ func readInputIntoMap(m *Map, filename string) {
 			m.PlaceObstable(Point{x, y})
 			x++
 
-		case '^':
-			m.SetStartingPoint(Point{x, y}, Vec{0, -1})
-			x++
-
 		default:
+			if dir, ok := directionFromGlyph(b); ok {
+				m.AddGuard(Point{x, y}, dir)
+			}
+
 			x++
 		}
+
+		width = max(width, x)
 	}
 
 	if x != 0 {
 		y++
 	}
 
-	m.SetDimensions(x, y)
+	m.SetDimensions(width, y)
+
+	if len(m.guards) == 0 {
+		return fmt.Errorf("no guard found in %s", filename)
+	}
+
+	return nil
 }
 
 func drawMap(m *Map, guard *Guard) string {
@@ -244,23 +604,90 @@ func drawMap(m *Map, guard *Guard) string {
 			if m.HasObstacle(p) {
 				str += "#"
 			} else if guard.point == p {
-				switch guard.direction {
-				case Vec{0, -1}:
-					str += "^"
+				str += guard.direction.Glyph()
+			} else {
+				str += "."
+			}
 
-				case Vec{1, 0}:
-					str += ">"
+		}
 
-				case Vec{0, 1}:
-					str += "v"
+		str += "\n"
+	}
 
-				case Vec{-1, 0}:
-					str += "<"
-				}
-			} else {
+	return str
+}
+
+// Which ways the guard walked through a point
+type Trail struct {
+	vertical, horizontal bool
+}
+
+// Walk the guard until it leaves the map, recording which ways it walked through each point
+func guardTrails(m *Map) map[Point]Trail {
+	guard := newGuard(m)
+	trails := make(map[Point]Trail)
+
+	mark := func(p Point, dir Vec) {
+		trail := trails[p]
+
+		if dir.x == 0 {
+			trail.vertical = true
+		} else {
+			trail.horizontal = true
+		}
+
+		trails[p] = trail
+	}
+
+	mark(guard.point, guard.direction)
+
+	for {
+		next, ok := nextVisit(&guard, m, noObstruction)
+		if !ok {
+			return trails
+		}
+
+		// The guard turns in place before stepping, so the point it steps from is walked in the new direction too
+		mark(guard.point, next.direction)
+
+		if !m.InBounds(next.point) {
+			return trails
+		}
+
+		mark(next.point, next.direction)
+
+		guard.point = next.point
+		guard.direction = next.direction
+	}
+}
+
+// Draw the map the way the puzzle does, with the guard's path and every obstruction that causes a loop
+// Vertical movement is a |, horizontal movement a -, and points walked both ways a +
+// Obstructions that cause a loop are an O
+func drawPath(m *Map, trails map[Point]Trail, loops map[Point]bool) string {
+	var str string
+
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			p := Point{x, y}
+			trail := trails[p]
+
+			switch {
+			case m.HasObstacle(p):
+				str += "#"
+			case loops[p]:
+				str += "O"
+			case p == m.startingPoint:
+				str += m.startingDirection.Glyph()
+			case trail.vertical && trail.horizontal:
+				str += "+"
+			case trail.vertical:
+				str += "|"
+			case trail.horizontal:
+				str += "-"
+			default:
 				str += "."
 			}
-
 		}
 
 		str += "\n"
@@ -268,3 +695,128 @@ func drawMap(m *Map, guard *Guard) string {
 
 	return str
 }
+
+// Size in pixels of each point when drawn as an image
+const cellSize = 5
+
+// Create an image of the map with the guard's path and every obstruction that causes a loop
+// Obstacles are grey, the path is drawn as white lines, obstructions that cause a loop are red and the start is green
+func pathImage(m *Map, trails map[Point]Trail, loops map[Point]bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, m.width*cellSize, m.height*cellSize))
+	mid := cellSize / 2
+
+	for y := 0; y < m.height; y++ {
+		for x := 0; x < m.width; x++ {
+			p := Point{x, y}
+			trail := trails[p]
+
+			for dy := 0; dy < cellSize; dy++ {
+				for dx := 0; dx < cellSize; dx++ {
+					var c color.Color = color.Black
+
+					switch {
+					case m.HasObstacle(p):
+						c = color.RGBA{128, 128, 128, 255}
+					case loops[p]:
+						c = color.RGBA{255, 0, 0, 255}
+					case p == m.startingPoint:
+						c = color.RGBA{0, 255, 0, 255}
+					case trail.vertical && dx == mid, trail.horizontal && dy == mid:
+						c = color.White
+					}
+
+					img.Set(x*cellSize+dx, y*cellSize+dy, c)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// Print the guard's path with every obstruction that causes a loop, and save it as a PNG
+func drawLoops(m *Map, filename string) {
+	trails := guardTrails(m)
+	loops := make(map[Point]bool)
+
+	for _, p := range fastLoopObstacles(m) {
+		loops[p] = true
+	}
+
+	fmt.Print(drawPath(m, trails, loops))
+	fmt.Println("Part 2:", len(loops))
+
+	f, err := os.Create(filename)
+
+	if err != nil {
+		panic(err)
+	}
+
+	defer f.Close()
+
+	if err := png.Encode(f, pathImage(m, trails, loops)); err != nil {
+		panic(err)
+	}
+}
+
+// Simulate every guard on the map moving in turn, with guards blocking each other like obstacles
+// Counts the distinct points visited by any guard until they've all left the map
+func multiGuards(m *Map) {
+	guards := slices.Clone(m.guards)
+	visitedPoints := make(map[Point]bool)
+	states := make(map[string]bool)
+
+	for _, guard := range guards {
+		visitedPoints[guard.point] = true
+	}
+
+	for len(guards) > 0 {
+		// If every guard is back where it was at the start of a previous round, they'll never leave
+		state := fmt.Sprint(guards)
+
+		if states[state] {
+			fmt.Println("Guards are stuck in a loop")
+			break
+		}
+
+		states[state] = true
+
+		for i := 0; i < len(guards); {
+			stepGuard(&guards[i], m, guards)
+
+			// Guards that leave the map no longer get a turn or block anyone
+			if !m.InBounds(guards[i].point) {
+				guards = slices.Delete(guards, i, i+1)
+				continue
+			}
+
+			visitedPoints[guards[i].point] = true
+			i++
+		}
+	}
+
+	fmt.Println("Guards:", len(m.guards))
+	fmt.Println("Visited:", len(visitedPoints))
+}
+
+// Move a guard one step, turning away from obstacles and other guards
+// A guard boxed in on every side waits where it is
+func stepGuard(g *Guard, m *Map, guards []Guard) {
+	dir := g.direction
+
+	for i := 0; i < 4; i++ {
+		point := g.point.Add(dir)
+
+		blocked := m.HasObstacle(point) || slices.ContainsFunc(guards, func(other Guard) bool {
+			return other.point == point
+		})
+
+		if !blocked {
+			g.point = point
+			g.direction = dir
+			return
+		}
+
+		dir = dir.Rotate()
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Example map from the puzzle
+const example = `....#.....
+.........#
+..........
+..#.......
+.......#..
+..........
+.#..^.....
+........#.
+#.........
+......#...
+`
+
+// Load a map the same way main does, from a file holding the given input
+func loadMap(t *testing.T, input string) Map {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "input.txt")
+
+	if err := os.WriteFile(filename, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newMap()
+
+	if err := readInputIntoMap(&m, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+// Sort obstructions into reading order so both implementations can be compared
+func sortedPoints(points []Point) []Point {
+	sorted := slices.Clone(points)
+
+	slices.SortFunc(sorted, func(a, b Point) int {
+		if a.y != b.y {
+			return a.y - b.y
+		}
+
+		return a.x - b.x
+	})
+
+	return sorted
+}
+
+func TestLoopObstaclesExample(t *testing.T) {
+	m := loadMap(t, example)
+
+	fast := sortedPoints(fastLoopObstacles(&m))
+	brute := sortedPoints(bruteLoopObstacles(&m))
+
+	if len(brute) != 6 {
+		t.Errorf("expected 6 obstructions from brute force, got %d", len(brute))
+	}
+
+	if !slices.Equal(fast, brute) {
+		t.Errorf("jump table found %v, brute force found %v", fast, brute)
+	}
+}
+
+func TestLoopObstaclesRandomMaps(t *testing.T) {
+	rng := rand.New(rand.NewPCG(6, 6))
+	compared := 0
+
+	for trial := 0; trial < 300; trial++ {
+		width, height := 4+rng.IntN(12), 4+rng.IntN(12)
+		density := 0.05 + rng.Float64()*0.25
+		grid := make([][]byte, height)
+
+		for y := range grid {
+			grid[y] = make([]byte, width)
+
+			for x := range grid[y] {
+				grid[y][x] = '.'
+
+				if rng.Float64() < density {
+					grid[y][x] = '#'
+				}
+			}
+		}
+
+		grid[rng.IntN(height)][rng.IntN(width)] = "^>v<"[rng.IntN(4)]
+
+		lines := make([]string, height)
+		for y, row := range grid {
+			lines[y] = string(row)
+		}
+
+		input := strings.Join(lines, "\n") + "\n"
+		m := loadMap(t, input)
+
+		// Obstructions only make sense when the guard would otherwise leave the map
+		if causesLoop(&m, noObstruction) {
+			continue
+		}
+
+		fast := sortedPoints(fastLoopObstacles(&m))
+		brute := sortedPoints(bruteLoopObstacles(&m))
+
+		if !slices.Equal(fast, brute) {
+			t.Fatalf("jump table found %v, brute force found %v on map\n%s", fast, brute, input)
+		}
+
+		compared++
+	}
+
+	if compared == 0 {
+		t.Error("every random map was skipped")
+	}
+}
@@ -4,121 +4,536 @@
 package main
 
 import (
+	"bytes"
+	"container/heap"
+	"errors"
 	"fmt"
+	"maps"
+	"math/rand/v2"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 )
 
+// A run of consecutive blocks
+type extent struct {
+	start, size int
+}
+
+// Index of the block just past the extent
+func (e extent) end() int {
+	return e.start + e.size
+}
+
 type file struct {
 	id, size int
+
+	// Runs of blocks the file resides at, in ascending order
+	// An unfragmented file has a single extent
+	extents []extent
+}
+
+// Add blocks to the file, joining them with any of the file's extents they touch
+func (f *file) add(e extent) {
+	i, _ := slices.BinarySearchFunc(f.extents, e.start, func(x extent, start int) int {
+		return x.start - start
+	})
+
+	f.extents = slices.Insert(f.extents, i, e)
+
+	if i+1 < len(f.extents) && f.extents[i].end() == f.extents[i+1].start {
+		f.extents[i].size += f.extents[i+1].size
+		f.extents = slices.Delete(f.extents, i+1, i+2)
+	}
+
+	if i > 0 && f.extents[i-1].end() == f.extents[i].start {
+		f.extents[i-1].size += f.extents[i].size
+		f.extents = slices.Delete(f.extents, i, i+1)
+	}
+}
+
+// Free space is bucketed by size, with extents this size or larger sharing the last bucket
+const maxBucket = 9
+
+func bucket(size int) int {
+	return min(size, maxBucket)
+}
+
+// Min-heap of the start indexes of free extents
+type freeHeap []int
+
+func (h freeHeap) Len() int           { return len(h) }
+func (h freeHeap) Less(i, j int) bool { return h[i] < h[j] }
+func (h freeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *freeHeap) Push(x any) {
+	*h = append(*h, x.(int))
+}
+
+func (h *freeHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+
+	return x
 }
 
 type filesystem struct {
-	files  []file
-	blocks []*file
+	files []*file
+	size  int
+
+	// Size of each free extent keyed by its start index, and the start index keyed by its end index
+	free     map[int]int
+	freeEnds map[int]int
+
+	// Start indexes of free extents, bucketed by size
+	// Extents that get taken or joined are left in their heap, and discarded once they reach the top
+	buckets [maxBucket + 1]freeHeap
+}
+
+// Mark blocks as free space, joining them with any free space they touch
+func (fs *filesystem) release(e extent) {
+	if start, ok := fs.freeEnds[e.start]; ok {
+		e = extent{start, fs.free[start] + e.size}
+		fs.unfree(start)
+	}
+
+	if size, ok := fs.free[e.end()]; ok {
+		fs.unfree(e.end())
+		e.size += size
+	}
+
+	fs.free[e.start] = e.size
+	fs.freeEnds[e.end()] = e.start
+	heap.Push(&fs.buckets[bucket(e.size)], e.start)
+}
+
+// Remove the free extent starting at the given index
+func (fs *filesystem) unfree(start int) {
+	delete(fs.freeEnds, start+fs.free[start])
+	delete(fs.free, start)
 }
 
-// Move a block from one location to another, leaving free space behind
-func (fs *filesystem) moveBlock(srcIdx, destIdx int) {
-	block := fs.blocks[srcIdx]
+// Take blocks from the front of the free extent starting at the given index
+// Whatever the blocks don't use is left as a smaller free extent
+func (fs *filesystem) allocate(start, size int) extent {
+	free := fs.free[start]
+	fs.unfree(start)
 
-	fs.blocks[destIdx] = block
-	fs.blocks[srcIdx] = nil
+	if free > size {
+		fs.release(extent{start + size, free - size})
+	}
+
+	return extent{start, size}
+}
+
+// Returns whether the free extent starting at the given index still belongs in the bucket
+func (fs *filesystem) inBucket(start, b int) bool {
+	size, ok := fs.free[start]
+
+	return ok && bucket(size) == b
+}
+
+// Find the lowest start index in a bucket
+// Entries at the top for extents that have since been taken or joined are discarded along the way
+func (fs *filesystem) lowest(b int) (int, bool) {
+	h := &fs.buckets[b]
+
+	for h.Len() > 0 && !fs.inBucket((*h)[0], b) {
+		heap.Pop(h)
+	}
+
+	if h.Len() == 0 {
+		return 0, false
+	}
+
+	return (*h)[0], true
 }
 
-// Move a file from one location to another, leaving free space behind
-func (fs *filesystem) moveFile(f file, destIdx int) {
-	blocks := fs.fileLocations(f)
+// Search the last bucket, which mixes sizes, for the preferred free extent before beforeIdx with room for the number of blocks given
+// better reports whether the first extent is preferred over the second
+func (fs *filesystem) searchLast(size, beforeIdx int, better func(a, b extent) bool) (int, bool) {
+	h := &fs.buckets[maxBucket]
+
+	// Every entry is visited anyway, so drop the ones that no longer belong while rebuilding the heap
+	*h = slices.DeleteFunc(*h, func(start int) bool {
+		return !fs.inBucket(start, maxBucket)
+	})
+
+	heap.Init(h)
+
+	found := extent{-1, 0}
+
+	for _, start := range *h {
+		e := extent{start, fs.free[start]}
 
-	// Iterate over the blocks the file currently resides at
-	// Move it to the destination space
-	// Source blocks become free space
-	for i, srcIdx := range blocks {
-		fs.blocks[destIdx+i] = &f
-		fs.blocks[srcIdx] = nil
+		if e.size >= size && start < beforeIdx && (found.start < 0 || better(e, found)) {
+			found = e
+		}
 	}
+
+	return found.start, found.start >= 0
 }
 
-// Returns all block indexes that make up a file
-// Block indexes will be returned in ascending order
-func (fs *filesystem) fileLocations(file file) []int {
-	indexes := make([]int, 0)
+// Looks for the leftmost free space before the given index that can contain the number of blocks given
+func (fs *filesystem) firstFit(size, beforeIdx int) (int, bool) {
+	found := -1
+
+	for b := bucket(size); b < maxBucket; b++ {
+		if start, ok := fs.lowest(b); ok && start < beforeIdx && (found < 0 || start < found) {
+			found = start
+		}
+	}
+
+	// The lowest extent in the last bucket might be too small, in which case the whole bucket has to be searched
+	start, ok := fs.lowest(maxBucket)
+
+	if ok && fs.free[start] < size {
+		start, ok = fs.searchLast(size, beforeIdx, func(a, b extent) bool { return a.start < b.start })
+	}
+
+	if ok && start < beforeIdx && (found < 0 || start < found) {
+		found = start
+	}
+
+	return found, found >= 0
+}
 
-	for i, b := range fs.blocks {
-		if b != nil && *b == file {
-			indexes = append(indexes, i)
+// Looks for the smallest free space before the given index that can contain the number of blocks given
+// Ties go to the leftmost space
+func (fs *filesystem) bestFit(size, beforeIdx int) (int, bool) {
+	for b := bucket(size); b < maxBucket; b++ {
+		if start, ok := fs.lowest(b); ok && start < beforeIdx {
+			return start, true
 		}
 	}
 
-	return indexes
+	return fs.searchLast(size, beforeIdx, func(a, b extent) bool {
+		return a.size < b.size || (a.size == b.size && a.start < b.start)
+	})
+}
+
+// Looks for the largest free space before the given index that can contain the number of blocks given
+// Ties go to the leftmost space
+func (fs *filesystem) worstFit(size, beforeIdx int) (int, bool) {
+	start, ok := fs.searchLast(size, beforeIdx, func(a, b extent) bool {
+		return a.size > b.size || (a.size == b.size && a.start < b.start)
+	})
+
+	if ok {
+		return start, true
+	}
+
+	for b := maxBucket - 1; b >= bucket(size); b-- {
+		if start, ok := fs.lowest(b); ok && start < beforeIdx {
+			return start, true
+		}
+	}
+
+	return 0, false
+}
+
+// Size of the largest free extent
+func (fs *filesystem) largestFree() int {
+	largest := 0
+
+	for _, size := range fs.free {
+		largest = max(largest, size)
+	}
+
+	return largest
+}
+
+// Move the last n blocks of a file into the free space starting at destIdx, leaving free space behind
+func (fs *filesystem) moveBlocks(f *file, n, destIdx int) {
+	last := &f.extents[len(f.extents)-1]
+	last.size -= n
+	src := extent{last.end(), n}
+
+	if last.size == 0 {
+		f.extents = f.extents[:len(f.extents)-1]
+	}
+
+	f.add(fs.allocate(destIdx, n))
+	fs.release(src)
+}
+
+// Move a whole file into the free space starting at destIdx, leaving free space behind
+func (fs *filesystem) moveFile(f *file, destIdx int) {
+	src := f.extents
+	f.extents = []extent{fs.allocate(destIdx, f.size)}
+
+	for _, e := range src {
+		fs.release(e)
+	}
 }
 
 func (fs *filesystem) String() string {
-	str := ""
+	blocks := make([]string, fs.size)
+
+	for i := range blocks {
+		blocks[i] = "."
+	}
 
-	for _, block := range fs.blocks {
-		if block != nil {
-			str += fmt.Sprintf("%d", block.id)
-		} else {
-			str += "."
+	for _, f := range fs.files {
+		for _, e := range f.extents {
+			for i := e.start; i < e.end(); i++ {
+				blocks[i] = strconv.Itoa(f.id)
+			}
 		}
 	}
 
-	return str
+	return strings.Join(blocks, "")
 }
 
-func newFilesystem(diskmap []byte) *filesystem {
-	// Diskmap is a series of block sizes
+// Read the run sizes out of a disk map
+// A disk map is either one digit per run, or for runs larger than 9 blocks, comma separated numbers like 12,0,15,3
+// Maps with a comma use the extended format, so a single run larger than 9 blocks needs a free space run after it, like 15,0
+// Line endings at the end of the map are ignored, but anything else that isn't a size is an error giving its byte offset
+func parseDiskMap(diskmap []byte) ([]int, error) {
+	diskmap = bytes.TrimRight(diskmap, "\r\n")
+	runs := make([]int, 0, len(diskmap))
+
+	if !bytes.ContainsRune(diskmap, ',') {
+		for i, b := range diskmap {
+			if b < '0' || b > '9' {
+				return nil, fmt.Errorf("byte %d: invalid digit %q", i, b)
+			}
+
+			runs = append(runs, int(b-'0'))
+		}
+
+		return runs, nil
+	}
+
+	offset := 0
+
+	for _, field := range bytes.Split(diskmap, []byte(",")) {
+		if len(field) == 0 {
+			return nil, fmt.Errorf("byte %d: missing size", offset)
+		}
+
+		if i := bytes.IndexFunc(field, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+			return nil, fmt.Errorf("byte %d: invalid digit %q", offset+i, field[i])
+		}
+
+		size, err := strconv.Atoi(string(field))
+		if err != nil {
+			return nil, fmt.Errorf("byte %d: size %s is too large", offset, field)
+		}
+
+		runs = append(runs, size)
+		offset += len(field) + 1
+	}
+
+	return runs, nil
+}
+
+func newFilesystem(runs []int) *filesystem {
+	// Runs are a series of block sizes
 	// Even indexes are files, odd indexes are empty space
 	// File IDs are in order, but are only incremented per file rather than per block
-	files := make([]file, 0)
-	blocks := make([]*file, 0)
-
-	for i, b := range diskmap {
-		blockSize, _ := strconv.Atoi(string(b))
-		var f *file
+	fs := &filesystem{
+		files:    make([]*file, 0),
+		free:     make(map[int]int),
+		freeEnds: make(map[int]int),
+	}
 
+	for i, blockSize := range runs {
 		if i%2 == 0 {
 			// Add file details to file listing
-			fileId := i / 2
-			f = &file{
-				id:   fileId,
-				size: blockSize,
+			f := &file{
+				id:      i / 2,
+				size:    blockSize,
+				extents: make([]extent, 0, 1),
+			}
+
+			if blockSize > 0 {
+				f.extents = append(f.extents, extent{fs.size, blockSize})
+			}
+
+			fs.files = append(fs.files, f)
+		} else if blockSize > 0 {
+			fs.release(extent{fs.size, blockSize})
+		}
+
+		fs.size += blockSize
+	}
+
+	return fs
+}
+
+// Returned by encode when a file or free space is too large for a single digit
+var errNeedsSplit = errors.New("layout needs run-splitting")
+
+// Lists the sizes of the runs making up the filesystem, alternating between files and free space as in a disk map
+// Files have to be unfragmented and appear in ID order, since a disk map has no way to express anything else
+// Empty files take up no space, so they're placed wherever the previous file ends
+func (fs *filesystem) runs() ([]int, error) {
+	runs := make([]int, 0, len(fs.files)*2)
+	pos := 0
+
+	for _, f := range fs.files {
+		start := pos
+
+		if len(f.extents) > 1 {
+			return nil, fmt.Errorf("file %d is fragmented into %d extents", f.id, len(f.extents))
+		}
+
+		if len(f.extents) == 1 {
+			start = f.extents[0].start
+		}
+
+		if start < pos {
+			return nil, fmt.Errorf("file %d is before the file ahead of it in ID order", f.id)
+		}
+
+		// Free space before the first file can't be expressed
+		if f.id == 0 && start > 0 {
+			return nil, fmt.Errorf("file 0 doesn't start the filesystem")
+		}
+
+		// Free space between this file and the previous one
+		if f.id > 0 {
+			runs = append(runs, start-pos)
+		}
+
+		runs = append(runs, f.size)
+		pos = start + f.size
+	}
+
+	if pos < fs.size {
+		runs = append(runs, fs.size-pos)
+	}
+
+	return runs, nil
+}
+
+// Encode the filesystem as a disk map in the same format as the input
+// Runs larger than 9 blocks can't be written as a single digit, which is reported with errNeedsSplit
+func (fs *filesystem) encode() ([]byte, error) {
+	runs, err := fs.runs()
+	if err != nil {
+		return nil, err
+	}
+
+	diskmap := make([]byte, len(runs))
+
+	for i, size := range runs {
+		if size > 9 {
+			kind := "free space"
+			if i%2 == 0 {
+				kind = fmt.Sprintf("file %d", i/2)
 			}
 
-			files = append(files, *f)
+			return nil, fmt.Errorf("%w: %s has %d blocks", errNeedsSplit, kind, size)
 		}
 
-		// Write blocks
-		// Each block points to the file details, or nil if free space
-		for range blockSize {
-			blocks = append(blocks, f)
+		diskmap[i] = byte('0' + size)
+	}
+
+	return diskmap, nil
+}
+
+// Encode the filesystem as a disk map in the extended format, which has no limit on run sizes
+func (fs *filesystem) encodeExtended() ([]byte, error) {
+	runs, err := fs.runs()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, len(runs))
+
+	for i, size := range runs {
+		fields[i] = strconv.Itoa(size)
+	}
+
+	// A single run wouldn't have a comma marking it as extended
+	if len(fields) == 1 {
+		fields = append(fields, "0")
+	}
+
+	return []byte(strings.Join(fields, ",")), nil
+}
+
+// Parse a disk map, panicking if it's invalid
+func mustParseDiskMap(diskmap []byte) []int {
+	runs, err := parseDiskMap(diskmap)
+	if err != nil {
+		panic(err)
+	}
+
+	return runs
+}
+
+// Generate a random disk map with the given number of files
+// Files are 1 to 9 blocks and free space is 0 to 9 blocks, and the map ends with a file like the puzzle input
+// Such a map encodes back to exactly the same bytes
+func randomDiskMap(files int, rng *rand.Rand) []byte {
+	diskmap := make([]byte, 0, files*2)
+
+	for i := 0; i < files; i++ {
+		if i > 0 {
+			diskmap = append(diskmap, byte('0'+rng.IntN(10)))
 		}
+
+		diskmap = append(diskmap, byte('1'+rng.IntN(9)))
 	}
 
-	return &filesystem{files, blocks}
+	return diskmap
 }
 
 func main() {
 	if cap(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [inputfile]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [inputfile] [block/first/best/worst/right/defrag/all]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s generate files [seed]\n", os.Args[0])
 		os.Exit(-1)
 	}
 
+	switch os.Args[1] {
+	case "generate":
+		generate()
+		return
+	}
+
 	content, err := os.ReadFile(os.Args[1])
 
 	if err != nil {
 		panic(err)
 	}
 
-	part1(content)
-	part2(content)
+	runs, err := parseDiskMap(content)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid disk map: %v\n", err)
+		os.Exit(-1)
+	}
+
+	if len(os.Args) < 3 {
+		part1(runs)
+		part2(runs)
+		return
+	}
+
+	found := false
+
+	for _, s := range strategies {
+		if os.Args[2] == "all" || os.Args[2] == s.name() {
+			report(s, newFilesystem(runs))
+			found = true
+		}
+	}
+
+	if !found {
+		fmt.Fprintf(os.Stderr, "Invalid strategy\n")
+		os.Exit(-1)
+	}
 }
 
 // Part one is to compress a filesystem by moving blocks from the end of the filesystem to empty space at the beginning
 // Part one does not care about fragmenting files
-func part1(input []byte) {
-	fs := newFilesystem(input)
+func part1(runs []int) {
+	fs := newFilesystem(runs)
 
 	compress(fs)
 
@@ -127,90 +542,238 @@ func part1(input []byte) {
 
 // Part two compresses the filesystem but also respects file fragmentation
 // Files are kept together and must be moved to a span of empty space big enough to house the whole file
-func part2(input []byte) {
-	fs := newFilesystem(input)
+func part2(runs []int) {
+	fs := newFilesystem(runs)
 
 	fileCompress(fs)
 
 	fmt.Println("Part 2 Checksum:", checksum(fs))
 }
 
-// Move blocks from the end of the filesystem to fill in empty space at the start of the filesystem
-// Used for Part 1
-func compress(fs *filesystem) {
-	tailIdx := len(fs.blocks) - 1
+// A way of rearranging the files on a filesystem
+type strategy interface {
+	name() string
 
-	for curIdx := 0; curIdx < tailIdx; curIdx++ {
-		curBlock := fs.blocks[curIdx]
+	// Rearrange the filesystem, returning the number of moves made
+	compact(fs *filesystem) int
+}
 
-		// Is this block free space?
-		if curBlock == nil {
-			// Find a block at the end of the filesystem to move
-			for tailIdx > curIdx {
-				tailBlock := fs.blocks[tailIdx]
+var strategies = []strategy{
+	blockStrategy{},
+	fitStrategy{"first", (*filesystem).firstFit, false},
+	fitStrategy{"best", (*filesystem).bestFit, false},
+	fitStrategy{"worst", (*filesystem).worstFit, false},
+	fitStrategy{"right", (*filesystem).firstFit, true},
+	defragStrategy{},
+}
 
-				// If a file is found, move it
-				if tailBlock != nil {
-					fs.moveBlock(tailIdx, curIdx)
-					break
-				}
+// Moves blocks one run at a time, splitting files across whatever free space is available, as in Part 1
+type blockStrategy struct{}
 
-				tailIdx--
-			}
-		}
-	}
+func (blockStrategy) name() string {
+	return "block"
 }
 
-// Move full files from the end of the filesystem to available space at the start of the filesystem, ensuring that files are not fragmented
-// Used for Part 2
-func fileCompress(fs *filesystem) {
-	// Starting at the last file index, descend the file list trying to move the file to free space at the start of the filesystem
+func (blockStrategy) compact(fs *filesystem) int {
+	return compress(fs)
+}
+
+// Moves whole files, last file first, into free space picked by fit
+type fitStrategy struct {
+	label string
+	fit   func(fs *filesystem, size, beforeIdx int) (int, bool)
+
+	// Whether files can move to free space after them, rather than only before them
+	right bool
+}
+
+func (s fitStrategy) name() string {
+	return s.label
+}
+
+func (s fitStrategy) compact(fs *filesystem) int {
+	moves := 0
+
 	for fileIdx := len(fs.files) - 1; fileIdx >= 0; fileIdx-- {
-		file := fs.files[fileIdx]
+		f := fs.files[fileIdx]
 
-		fileStartIdx := fs.fileLocations(file)[0]
+		if f.size == 0 {
+			continue
+		}
+
+		beforeIdx := f.extents[0].start
+		if s.right {
+			beforeIdx = fs.size
+		}
 
-		// Look for free space starting from the beginning of the filesystem that can house the file
-		if freeBlockIdx, ok := seekfree(fs, file.size, fileStartIdx); ok {
-			fs.moveFile(file, freeBlockIdx)
+		if freeIdx, ok := s.fit(fs, f.size, beforeIdx); ok {
+			fs.moveFile(f, freeIdx)
+			moves++
 		}
 	}
+
+	return moves
+}
+
+// Packs every file into a single extent at the start of the filesystem, keeping the order the files start in
+// All of the free space ends up as one extent at the end
+type defragStrategy struct{}
+
+func (defragStrategy) name() string {
+	return "defrag"
 }
 
-// Looks for empty space starting at the beginning of the fs to contain the number of blocks given
-func seekfree(fs *filesystem, size int, beforeIdx int) (int, bool) {
-	for curIdx := 0; curIdx < beforeIdx; curIdx++ {
-		if fs.blocks[curIdx] != nil {
+func (defragStrategy) compact(fs *filesystem) int {
+	files := slices.Clone(fs.files)
+
+	slices.SortFunc(files, func(a, b *file) int {
+		if len(a.extents) == 0 || len(b.extents) == 0 {
+			return len(a.extents) - len(b.extents)
+		}
+
+		return a.extents[0].start - b.extents[0].start
+	})
+
+	// Lay the files out again from scratch, as if copying them to an empty filesystem
+	clear(fs.free)
+	clear(fs.freeEnds)
+	clear(fs.buckets[:])
+
+	moves, next := 0, 0
+
+	for _, f := range files {
+		if f.size == 0 {
 			continue
 		}
 
-		freeStart := curIdx
-		freeSize := 0
+		packed := extent{next, f.size}
 
-		// Found a block of free space
-		// Loop over the next blocks to find where free space ends
-		for fs.blocks[curIdx] == nil {
-			freeSize++
-			curIdx++
+		if len(f.extents) != 1 || f.extents[0] != packed {
+			f.extents = []extent{packed}
+			moves++
 		}
 
-		if freeSize >= size {
-			return freeStart, true
+		next = packed.end()
+	}
+
+	if next < fs.size {
+		fs.release(extent{next, fs.size - next})
+	}
+
+	return moves
+}
+
+// Run a strategy and print how fragmented the filesystem is afterwards
+// Fragments are counted as the number of files split into each number of extents
+func report(s strategy, fs *filesystem) {
+	moves := s.compact(fs)
+	fragments := make(map[int]int)
+	var mostFragmented *file
+
+	for _, f := range fs.files {
+		fragments[len(f.extents)]++
+
+		if mostFragmented == nil || len(f.extents) > len(mostFragmented.extents) {
+			mostFragmented = f
 		}
 	}
 
-	return 0, false
+	counts := make([]string, 0, len(fragments))
+
+	for _, n := range slices.Sorted(maps.Keys(fragments)) {
+		counts = append(counts, fmt.Sprintf("%d:%d", n, fragments[n]))
+	}
+
+	fmt.Println("Strategy:", s.name())
+	fmt.Println("  Moves:", moves)
+	fmt.Println("  Fragments per file:", strings.Join(counts, " "))
+
+	if mostFragmented != nil {
+		fmt.Println("  Most fragmented file:", mostFragmented.id, "with", len(mostFragmented.extents), "extents")
+	}
+
+	fmt.Println("  Largest free extent:", fs.largestFree())
+	fmt.Println("  Checksum:", checksum(fs))
+}
+
+// Move blocks from the end of the filesystem to fill in empty space at the start of the filesystem
+// Blocks are moved a run at a time, filling the leftmost free extent with as much of the last file as fits
+// Returns the number of runs moved
+// Used for Part 1
+func compress(fs *filesystem) int {
+	moves := 0
+
+	for fileIdx := len(fs.files) - 1; fileIdx >= 0; fileIdx-- {
+		f := fs.files[fileIdx]
+
+		// Keep moving the file's last blocks until there's no free space before them
+		for len(f.extents) > 0 {
+			last := f.extents[len(f.extents)-1]
+			freeIdx, ok := fs.firstFit(1, last.start)
+
+			if !ok {
+				break
+			}
+
+			fs.moveBlocks(f, min(fs.free[freeIdx], last.size), freeIdx)
+			moves++
+		}
+	}
+
+	return moves
+}
+
+// Move full files from the end of the filesystem to available space at the start of the filesystem, ensuring that files are not fragmented
+// Returns the number of files moved
+// Used for Part 2
+func fileCompress(fs *filesystem) int {
+	return fitStrategy{"first", (*filesystem).firstFit, false}.compact(fs)
 }
 
 // Checksum multiplies each block's index with its file ID and adds them all up
+// Each extent's indexes form a series, so they're summed without visiting every block
 func checksum(fs *filesystem) int {
 	checksum := 0
 
-	for i, block := range fs.blocks {
-		if block != nil {
-			checksum += i * block.id
+	for _, f := range fs.files {
+		for _, e := range f.extents {
+			checksum += f.id * (e.size*e.start + e.size*(e.size-1)/2)
 		}
 	}
 
 	return checksum
 }
+
+// Seed for the random number generator from the argument at the given index, or a random seed if it isn't given
+func seedArg(i int) uint64 {
+	if len(os.Args) <= i {
+		return rand.Uint64()
+	}
+
+	seed, err := strconv.ParseUint(os.Args[i], 10, 64)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid seed\n")
+		os.Exit(-1)
+	}
+
+	return seed
+}
+
+// Print a randomly generated disk map
+func generate() {
+	if cap(os.Args) < 3 {
+		fmt.Fprintf(os.Stderr, "Usage: %s generate files [seed]\n", os.Args[0])
+		os.Exit(-1)
+	}
+
+	files, err := strconv.Atoi(os.Args[2])
+	if err != nil || files < 1 {
+		fmt.Fprintf(os.Stderr, "Invalid files\n")
+		os.Exit(-1)
+	}
+
+	seed := seedArg(3)
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	fmt.Println(string(randomDiskMap(files, rng)))
+}
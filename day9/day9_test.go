@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseDiskMap(t *testing.T) {
+	tests := []struct {
+		input string
+		runs  []int
+		err   string
+	}{
+		{"2333133121414131402", []int{2, 3, 3, 3, 1, 3, 3, 1, 2, 1, 4, 1, 4, 1, 3, 1, 4, 0, 2}, ""},
+		{"12345\n", []int{1, 2, 3, 4, 5}, ""},
+		{"12345\r\n", []int{1, 2, 3, 4, 5}, ""},
+		{"12,3,4", []int{12, 3, 4}, ""},
+		{"12,3,4\r\n", []int{12, 3, 4}, ""},
+		{"23a3", nil, "byte 2"},
+		{"12,,3", nil, "byte 3"},
+		{"1,2,", nil, "byte 4"},
+		{"1,99999999999999999999", nil, "byte 2"},
+	}
+
+	for _, tt := range tests {
+		runs, err := parseDiskMap([]byte(tt.input))
+
+		if tt.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err+":") {
+				t.Errorf("%q: expected error at %s, got %v", tt.input, tt.err, err)
+			}
+
+			continue
+		}
+
+		if err != nil || !slices.Equal(runs, tt.runs) {
+			t.Errorf("%q: expected %v, got %v (%v)", tt.input, tt.runs, runs, err)
+		}
+	}
+}
+
+// Seeded so failures can be reproduced
+const roundTripSeed = 2024
+
+func TestEncodeRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(roundTripSeed, roundTripSeed))
+
+	for trial := 0; trial < 500; trial++ {
+		diskmap := randomDiskMap(1+rng.IntN(200), rng)
+
+		runs, err := parseDiskMap(diskmap)
+		if err != nil {
+			t.Fatalf("%s: %v", diskmap, err)
+		}
+
+		got, err := newFilesystem(runs).encode()
+		if err != nil || !bytes.Equal(got, diskmap) {
+			t.Fatalf("%s encoded as %s: %v", diskmap, got, err)
+		}
+	}
+}
+
+func TestCompactedLayoutsRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewPCG(roundTripSeed, roundTripSeed))
+	encoded := make(map[string]int)
+
+	for trial := 0; trial < 200; trial++ {
+		diskmap := randomDiskMap(1+rng.IntN(200), rng)
+
+		for _, s := range strategies {
+			fs := newFilesystem(mustParseDiskMap(diskmap))
+			s.compact(fs)
+
+			got, err := fs.encode()
+
+			// Layouts with large runs can still be written in the extended format
+			if errors.Is(err, errNeedsSplit) {
+				got, err = fs.encodeExtended()
+			}
+
+			// Fragmented or reordered files can't be written as a disk map at all
+			if err != nil {
+				continue
+			}
+
+			parsed, err := parseDiskMap(got)
+			if err != nil {
+				t.Fatalf("%s compacted with %s encoded as %s, which doesn't parse: %v", diskmap, s.name(), got, err)
+			}
+
+			if fs2 := newFilesystem(parsed); fs2.String() != fs.String() || checksum(fs2) != checksum(fs) {
+				t.Fatalf("%s compacted with %s encoded as %s, which parses to a different layout", diskmap, s.name(), got)
+			}
+
+			encoded[s.name()]++
+		}
+	}
+
+	// Defragmenting always leaves files unfragmented and in order, so every one of its layouts can be encoded
+	if encoded["defrag"] != 200 {
+		t.Errorf("expected all 200 defragmented layouts to encode, got %d", encoded["defrag"])
+	}
+}